@@ -1,18 +1,21 @@
 package assets
 
 import (
-	"bufio"
 	"bytes"
-	"crypto/md5"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"log"
+	"mime"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-playground/bundler"
 	"github.com/tdewolff/minify"
@@ -22,9 +25,12 @@ import (
 
 const (
 	oldNewSeparator = " --> "
-	manifestFile    = "/manifest.txt"
+	manifestFile    = "manifest.json"
 	cssHTMLTag      = "css_tag"
 	jsHTMLTag       = "js_tag"
+	assetURLFunc    = "asset_url"
+	cssInlineFunc   = "css_inline"
+	jsInlineFunc    = "js_inline"
 )
 
 // RunMode is the type that determines which mode the template.FuncMap functions whould run in.
@@ -36,310 +42,505 @@ const (
 	Production
 )
 
-const (
-	jsTag  = `<script type="text/javascript" src="%s"></script>`
-	cssTag = `<link type="text/css" rel="stylesheet" href="%s">`
+var (
+	m            *minify.M
+	minifierOnce sync.Once
 )
 
-var m *minify.M
-
+// initMinifier sets up the minifier once, it's safe for concurrent use thereafter.
 func initMinifier() {
-
-	if m != nil {
-		return
-	}
-
-	m = minify.New()
-	m.AddFunc("text/css", css.Minify)
-	m.AddFunc("text/javascript", js.Minify)
+	minifierOnce.Do(func() {
+		m = minify.New()
+		m.AddFunc("text/css", css.Minify)
+		m.AddFunc("text/javascript", js.Minify)
+	})
 }
 
 // Generate processes (bundles, compresses...) the assets for use and creates the Manifest file
 // NOTE: no compression yet until there is a native and establishes compressor written in Go
+//
+// Generate is a shortcut for NewPipeline(Config{...}).Build()
 func Generate(dirname string, outputDir string, relativeToDir bool, leftDelim string, rightDelim string, extensions map[string]struct{}) ([]*bundler.ProcessedFile, string, error) {
 
+	p := NewPipeline(Config{
+		InputDir:      dirname,
+		OutputDir:     outputDir,
+		RelativeToDir: relativeToDir,
+		LeftDelim:     leftDelim,
+		RightDelim:    rightDelim,
+		Extensions:    extensions,
+	})
+
+	return p.Build()
+}
+
+func (p *Pipeline) generate() ([]*bundler.ProcessedFile, string, error) {
+
 	initMinifier()
 
-	dirname = filepath.Clean(dirname)
-	outputDir = filepath.Clean(outputDir) + string(filepath.Separator)
+	out, ok := p.out.(WriteFS)
+	if !ok {
+		return nil, "", errors.New("output filesystem is not writable")
+	}
+
+	if p.cfg.Output == nil {
+		if err := os.MkdirAll(p.cfg.OutputDir, os.FileMode(0777)); err != nil {
+			return nil, "", err
+		}
+	}
 
-	abs, err := filepath.Abs(outputDir)
+	// verify dirname is actually a DIR, symlinks are followed
+	fi, err := fs.Stat(p.src, ".")
 	if err != nil {
 		return nil, "", err
 	}
 
-	if err = os.MkdirAll(abs, os.FileMode(0777)); err != nil {
-		return nil, "", err
+	if !fi.IsDir() {
+		return nil, "", errors.New("dirname passed in is not a directory")
 	}
 
-	// verify dirname is actually a DIR + do symlink check
-	fi, err := os.Lstat(dirname)
+	// a missing or unreadable previous manifest just means there is nothing to clean up
+	prev, _ := p.loadManifest(out)
+	cache := p.newCacheState(out)
+
+	assets, err := p.bundleDir(out, ".", cache)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if !fi.IsDir() {
+	if err = checkCollisions(assets); err != nil {
+		return nil, "", err
+	}
 
-		// check if symlink
-		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+	if err = p.writeManifest(out, assets); err != nil {
+		return nil, "", err
+	}
 
-			link, err := filepath.EvalSymlinks(dirname)
-			if err != nil {
-				return nil, "", errors.New("Error Resolving Symlink:" + err.Error())
-			}
+	p.removeStale(out, prev, cache, assets)
 
-			fi, err = os.Stat(link)
-			if err != nil {
-				return nil, "", err
-			}
-
-			if !fi.IsDir() {
-				return nil, "", errors.New("dirname passed in is not a directory")
-			}
+	if err = p.writeCache(out, cache); err != nil {
+		return nil, "", err
+	}
 
-			dirname = link
+	processed := make([]*bundler.ProcessedFile, len(assets))
 
-		} else {
-			return nil, "", errors.New("dirname passed in is not a directory")
-		}
+	for i, a := range assets {
+		processed[i] = &bundler.ProcessedFile{OriginalFilename: path.Join(p.prefix, a.Name), NewFilename: a.File}
 	}
 
-	var manifest string
+	return processed, p.ManifestPath(), nil
+}
+
+// bundleDir processes all files within dir using Config.Workers goroutines,
+// the Assets are returned in the lexical order of their names.
+func (p *Pipeline) bundleDir(out WriteFS, dir string, cache *cacheState) ([]*Asset, error) {
+
+	var names []string
 
-	if outputDir == "" {
-		manifest = dirname + manifestFile
-	} else {
-		manifest = outputDir + dirname + manifestFile
+	err := p.walkFiles(dir, func(name string, _ fs.FileInfo) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	f, err := os.Open(manifest)
-	if err == nil {
-		defer f.Close()
-		scanner := bufio.NewScanner(f)
+	files := make([]*Asset, len(names))
+	errs := make([]error, len(names))
+	jobs := make(chan int)
 
-		for scanner.Scan() {
-			files := strings.SplitN(scanner.Text(), oldNewSeparator, 2)
+	var wg sync.WaitGroup
+	var failed atomic.Bool
 
-			fmt.Println("Removing Existing File:", files[1])
-			os.Remove(files[1])
-		}
+	for w := 0; w < p.workers(); w++ {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+
+				// once a file failed the remaining ones are skipped
+				if failed.Load() {
+					continue
+				}
 
-		os.Remove(manifest)
+				if files[i], errs[i] = p.processFile(out, names[i], cache); errs[i] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
 	}
 
-	processed, err := bundleDir(dirname, "", false, "", extensions, outputDir, relativeToDir, dirname, leftDelim, rightDelim)
-	if err != nil {
-		return nil, "", err
+	for i := range names {
+		jobs <- i
 	}
 
-	var buff bytes.Buffer
+	close(jobs)
+	wg.Wait()
 
-	for _, file := range processed {
-		buff.WriteString(filepath.FromSlash(file.OriginalFilename))
-		buff.WriteString(oldNewSeparator)
-		buff.WriteString(filepath.FromSlash(file.NewFilename))
-		buff.WriteString("\n")
-	}
+	processed := make([]*Asset, 0, len(files))
 
-	if err = ioutil.WriteFile(manifest, buff.Bytes(), 0644); err != nil {
-		return nil, "", err
+	for i, file := range files {
+
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+
+		if file != nil {
+			processed = append(processed, file)
+		}
 	}
 
-	return processed, manifest, nil
+	return processed, nil
 }
 
-func bundleDir(path string, dir string, isSymlinkDir bool, symlinkDir string, extensions map[string]struct{}, output string, relativeToDir bool, relativeDir string, leftDelim string, rightDelim string) ([]*bundler.ProcessedFile, error) {
-
-	var p string
-	var ext string
-	var processed []*bundler.ProcessedFile
+// workers returns the number of files to process concurrently.
+func (p *Pipeline) workers() int {
 
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	if p.cfg.Workers > 0 {
+		return p.cfg.Workers
 	}
-	defer f.Close()
 
-	files, err := f.Readdir(0)
+	return runtime.NumCPU()
+}
+
+// walkFiles calls fn for every file within dir of the input, recursively and in lexical order,
+// symlinks are followed; the output of Build is skipped when it's written within the input.
+func (p *Pipeline) walkFiles(dir string, fn func(name string, fi fs.FileInfo) error) error {
+	return p.walkDir(dir, p.outputFilter(), fn)
+}
+
+func (p *Pipeline) walkDir(dir string, isOutput func(name string) bool, fn func(name string, fi fs.FileInfo) error) error {
+
+	files, err := fs.ReadDir(p.src, dir)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	for _, file := range files {
 
-		info := file
-		p = path + string(os.PathSeparator) + file.Name()
-		fPath := p
+		name := path.Join(dir, file.Name())
 
-		if isSymlinkDir {
-			fPath = strings.Replace(p, dir, symlinkDir, 1)
+		if isOutput(name) {
+			continue
 		}
 
-		if file.IsDir() {
+		var fi fs.FileInfo
 
-			processedFiles, err := bundleDir(p, p, isSymlinkDir, symlinkDir+string(os.PathSeparator)+info.Name(), extensions, output, relativeToDir, relativeDir, leftDelim, rightDelim)
+		if file.Type()&fs.ModeSymlink == fs.ModeSymlink {
+			fi, err = fs.Stat(p.src, name)
 			if err != nil {
-				return nil, err
+				return errors.New("Error Resolving Symlink:" + err.Error())
 			}
+		} else if fi, err = file.Info(); err != nil {
+			return err
+		}
 
-			processed = append(processed, processedFiles...)
+		if fi.IsDir() {
+
+			if err = p.walkDir(name, isOutput, fn); err != nil {
+				return err
+			}
 
 			continue
 		}
 
-		if file.Mode()&os.ModeSymlink == os.ModeSymlink {
+		if err = fn(name, fi); err != nil {
+			return err
+		}
+	}
 
-			link, err := filepath.EvalSymlinks(p)
-			if err != nil {
-				log.Panic("Error Resolving Symlink", err)
-			}
+	return nil
+}
 
-			fi, err := os.Stat(link)
-			if err != nil {
-				log.Panic(err)
-			}
+// outputFilter returns a func reporting if the name, within the input, is part of the output of Build
+// and mustn't be treated as a source file. With the output written next to the source files, see
+// outputOverlap, these are the files listed in the current manifest and build cache.
+func (p *Pipeline) outputFilter() func(name string) bool {
 
-			info = fi
+	switch p.overlap {
+	case "":
+		return func(string) bool { return false }
+	case ".":
+	default:
+		return func(name string) bool { return name == p.overlap || strings.HasPrefix(name, p.overlap+"/") }
+	}
 
-			if fi.IsDir() {
+	files := map[string]bool{manifestFile: true, legacyManifestFile: true, cacheFile: true}
 
-				processedFiles, err := bundleDir(link, link, true, fPath, extensions, output, relativeToDir, relativeDir, leftDelim, rightDelim)
-				if err != nil {
-					return nil, err
-				}
+	add := func(a *Asset) {
+		if a != nil {
+			for _, file := range a.files() {
+				files[strings.TrimPrefix(file, p.prefix+"/")] = true
+			}
+		}
+	}
 
-				processed = append(processed, processedFiles...)
+	// the unhashed copies aren't listed, they are the source files themselves
+	if manifest, err := p.loadManifest(p.out); err == nil {
+		for _, a := range manifest.Assets {
+			add(a)
+		}
+	}
 
-				continue
-			}
+	if cache, err := p.readCache(p.out); err == nil {
+		for _, entry := range cache.Entries {
+			add(entry.Asset)
 		}
+	}
 
-		// if we get here, it's a file
-		ext = filepath.Ext(fPath)
+	return func(name string) bool { return files[name] }
+}
 
-		if _, ok := extensions[ext]; !ok {
+// isProcessed returns if the file name has one of the extensions to be bundled and minified.
+func (p *Pipeline) isProcessed(name string) bool {
+	_, ok := p.cfg.Extensions[path.Ext(name)]
+	return ok
+}
 
-			// just copy file to final location
-			if err := copyFile(fPath, output); err != nil {
-				return nil, err
-			}
-			continue
-		}
+// processFile bundles the file name when it has one of the processed extensions,
+// otherwise it's copied as is as well as fingerprinted. Files found in the cache
+// are left untouched.
+func (p *Pipeline) processFile(out WriteFS, name string, cache *cacheState) (*Asset, error) {
 
-		// process file
-		file, err := bundleFile(p, output, relativeToDir, relativeDir, leftDelim, rightDelim, ext)
-		if err != nil {
+	if entry, ok := cache.lookup(p, out, name); ok {
+		return entry.Asset, nil
+	}
+
+	if !p.isProcessed(name) {
+		return p.copyFile(out, name, cache)
+	}
+
+	return p.bundleFile(out, name, path.Ext(name), cache)
+}
+
+// copyFile copies the file name to the output as is, for the references to it's original name,
+// and writes the fingerprinted copy, which can be cached forever, returning it's Asset.
+func (p *Pipeline) copyFile(out WriteFS, name string, cache *cacheState) (*Asset, error) {
+
+	b, err := fs.ReadFile(p.src, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// written next to the source files the copy is the source file itself
+	if p.overlap != "." {
+		if err = out.WriteFile(path.Join(p.prefix, name), b); err != nil {
 			return nil, err
 		}
+	}
 
-		processed = append(processed, file)
+	hash := p.contentHash(b)
+	newName := p.hashedName(name, path.Ext(name), hash)
+
+	if err = out.WriteFile(newName, b); err != nil {
+		return nil, err
 	}
 
-	return processed, nil
+	a := &Asset{
+		Name:         name,
+		URL:          p.publicURL(p.cfg.BaseURL, newName),
+		File:         newName,
+		Hash:         hash,
+		Size:         int64(len(b)),
+		MinifiedSize: int64(len(b)),
+		MIMEType:     mime.TypeByExtension(path.Ext(name)),
+		Integrity:    integrity(p.cfg.Integrity, b),
+	}
+
+	cache.store(name, &cacheEntry{Sources: map[string]string{name: sourceHash(b)}, Asset: a, Copied: true})
+
+	return a, nil
 }
 
-func copyFile(path string, output string) error {
+func (p *Pipeline) bundleFile(out WriteFS, name string, extension string, cache *cacheState) (*Asset, error) {
 
-	dirname := output + string(filepath.Separator) + path
+	state := &bundleState{sources: map[string]string{}, rewrite: true}
+	buff := new(bytes.Buffer)
 
-	if err := os.MkdirAll(filepath.Dir(dirname), os.FileMode(0777)); err != nil {
-		return err
+	if p.cfg.SourceMaps && (extension == ".js" || extension == ".css") {
+		state.contents = map[string][]byte{}
 	}
 
-	f, err := os.Open(path)
-	if err != nil {
-		return err
+	if err := p.bundle(buff, name, state); err != nil {
+		return nil, err
 	}
-	defer f.Close()
 
-	out, err := os.Create(dirname)
-	if err != nil {
-		return err
+	bundled := buff.Bytes()
+	b := bundled
+	hash := p.contentHash(b)
+
+	newName := p.hashedName(name, extension, hash)
+
+	// perform minification
+	if extension == ".js" || extension == ".css" {
+
+		buff = new(bytes.Buffer)
+		mimeType := "text/javascript"
+
+		if extension == ".css" {
+			mimeType = "text/css"
+		}
+
+		if err := m.Minify(mimeType, buff, bytes.NewReader(b)); err != nil {
+			return nil, err
+		}
+
+		b = buff.Bytes()
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, f)
-	if err != nil {
-		return err
+	a := &Asset{
+		Name:       name,
+		URL:        p.publicURL(p.cfg.BaseURL, newName),
+		File:       newName,
+		Hash:       hash,
+		Size:       int64(len(bundled)),
+		MIMEType:   mime.TypeByExtension(extension),
+		Includes:   state.includes,
+		References: state.references,
 	}
 
-	return nil
-}
+	if state.contents != nil {
 
-func bundleFile(path string, output string, relativeToDir bool, relativeDir string, leftDelim string, rightDelim string, extension string) (*bundler.ProcessedFile, error) {
+		comment, err := p.writeSourceMap(out, a, bundled, b, state)
+		if err != nil {
+			return nil, err
+		}
 
-	f, err := os.Open(path)
-	if err != nil {
+		b = append(b, comment...)
+	}
+
+	if err := out.WriteFile(newName, b); err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	newFile, err := ioutil.TempFile("", filepath.Base(f.Name()))
+	variants, err := p.compress(out, newName, b)
 	if err != nil {
 		return nil, err
 	}
 
-	defer newFile.Close()
+	a.MinifiedSize = int64(len(b))
+	a.Integrity = integrity(p.cfg.Integrity, b)
+	a.Variants = variants
 
-	if err = bundler.Bundle(f, newFile, filepath.Dir(path), relativeToDir, relativeDir, leftDelim, rightDelim); err != nil {
-		return nil, err
-	}
+	cache.store(name, &cacheEntry{Sources: state.sources, Asset: a})
 
-	var newName string
+	return a, nil
+}
 
-	dirname, filename := filepath.Split(path)
-	origDir := dirname
-	ext := filepath.Ext(filename)
-	filename = filepath.Base(filename)
+// bundleState is the state of bundling a single entry file.
+type bundleState struct {
+	includes   []string            // included files, once, in the order they are first encountered
+	comments   bool                // write comments marking the start and end of every included file
+	sources    map[string]string   // when not nil, the content hashes of the file and all it's includes
+	edges      map[string][]string // when not nil, the files directly included by each file
+	stack      []string            // the files currently being bundled, used to detect include cycles
+	contents   map[string][]byte   // when not nil, the contents of the bundled files and the segments are recorded
+	segments   []segment           // the origin of every text written, for source maps
+	offset     int                 // the number of bytes written
+	rewrite    bool                // rewrite css url() and @import references to the hashed files
+	dev        bool                // with rewrite, rewrite the references to the source files served by the DevHandler
+	references []string            // files referenced by url() and @import, and the files their URLs depend on
+}
 
-	if output != "" {
-		dirname = output + string(filepath.Separator) + dirname + string(filepath.Separator)
+// bundle combines the file name and all of it's includes, removing the include delims,
+// and writes the result to w.
+func (p *Pipeline) bundle(w io.Writer, name string, state *bundleState) error {
+
+	b, err := fs.ReadFile(p.src, name)
+	if err != nil {
+		return err
+	}
+
+	if state.sources != nil {
+		state.sources[name] = sourceHash(b)
 	}
 
-	newName = dirname + filename[0:strings.LastIndex(filename, ext)]
-	origDir += filename[0:strings.LastIndex(filename, ext)]
+	if state.contents != nil {
+		state.contents[name] = b
+	}
 
-	b, err := ioutil.ReadFile(newFile.Name())
+	l, err := bundler.NewLexer(name, bytes.NewReader(b), p.cfg.LeftDelim, p.cfg.RightDelim)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	h := md5.New()
-	h.Write(b)
-	hash := string(h.Sum(nil))
+	state.stack = append(state.stack, name)
+	defer func() { state.stack = state.stack[:len(state.stack)-1] }()
 
-	hashName := "-" + fmt.Sprintf("%x", hash) + ext
-	newName += hashName
-	origDir += hashName
+	for {
+		itm := l.NextItem()
 
-	if err = os.MkdirAll(filepath.Dir(newName), os.FileMode(0777)); err != nil {
-		return nil, err
-	}
+		switch itm.Type {
+		case bundler.ItemText:
+			pieces := []piece{{val: itm.Val, pos: int(itm.Pos)}}
 
-	buff := new(bytes.Buffer)
+			if state.rewrite && path.Ext(name) == ".css" {
+				if pieces, err = p.rewriteCSS(name, b, itm.Val, int(itm.Pos), state); err != nil {
+					return err
+				}
+			}
 
-	// perform minification
-	if extension == ".js" {
+			for _, pc := range pieces {
 
-		if err := m.Minify("text/javascript", buff, bytes.NewReader(b)); err != nil {
-			return nil, err
-		}
+				if state.contents != nil {
+					state.segments = append(state.segments, segment{offset: state.offset, file: name, pos: pc.pos})
+				}
 
-	} else if extension == ".css" {
-		if err := m.Minify("text/css", buff, bytes.NewReader(b)); err != nil {
-			return nil, err
+				n, err := io.WriteString(w, pc.val)
+				if err != nil {
+					return err
+				}
+
+				state.offset += n
+			}
+		case bundler.ItemFile:
+			include := p.includePath(name, itm.Val)
+
+			if err = checkCycle(state.stack, include, name, b, itm); err != nil {
+				return err
+			}
+
+			if !contains(state.includes, include) {
+				state.includes = append(state.includes, include)
+			}
+
+			if state.edges != nil && !contains(state.edges[name], include) {
+				state.edges[name] = append(state.edges[name], include)
+			}
+
+			if state.comments {
+				n, _ := fmt.Fprintf(w, "\n/* begin %s */\n", commentSafe(include))
+				state.offset += n
+			}
+
+			if err = p.bundle(w, include, state); err != nil {
+				return err
+			}
+
+			if state.comments {
+				n, _ := fmt.Fprintf(w, "\n/* end %s */\n", commentSafe(include))
+				state.offset += n
+			}
+		case bundler.ItemEOF:
+			return nil
+		case bundler.ItemError:
+			return errors.New(itm.Val)
 		}
 	}
+}
 
-	if err := os.Remove(newFile.Name()); err != nil {
-		return nil, err
-	}
+// includePath returns the path, within the input filesystem, of the file included from
+// the file name.
+func (p *Pipeline) includePath(name string, include string) string {
 
-	if err := ioutil.WriteFile(newName, buff.Bytes(), 0644); err != nil {
-		return nil, err
+	if p.cfg.RelativeToDir {
+		return outputPrefix(path.Clean(include))
 	}
 
-	return &bundler.ProcessedFile{OriginalFilename: path, NewFilename: origDir}, nil
+	return outputPrefix(path.Join(path.Dir(name), include))
 }
 
 // LoadManifestFiles reads the manifest file generated by the Generate() command
@@ -350,7 +551,10 @@ func LoadManifestFiles(dirname string, mode RunMode, relativeToDir bool, leftDel
 	var err error
 
 	if mode == Production {
-		f, err = os.Open(dirname + manifestFile)
+		f, err = os.Open(filepath.Join(dirname, manifestFile))
+		if os.IsNotExist(err) {
+			f, err = os.Open(filepath.Join(dirname, legacyManifestFile))
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -360,119 +564,240 @@ func LoadManifestFiles(dirname string, mode RunMode, relativeToDir bool, leftDel
 	return ProcessManifestFiles(f, dirname, mode, relativeToDir, leftDelim, rightDelim)
 }
 
-// ProcessManifestFiles reads an existing manifest file generated by the Generate() command
-// in Production mode and returns template.FuncMap for the provided RunMode
+// ProcessManifestFiles reads an existing manifest file, in either the JSON or legacy text format,
+// generated by the Generate() command in Production mode and returns template.FuncMap for the provided RunMode
 func ProcessManifestFiles(manifest io.Reader, dirname string, mode RunMode, relativeToDir bool, leftDelim string, rightDelim string) (template.FuncMap, error) {
 
-	mapped := map[string]string{}
-	dirname = filepath.Clean(dirname) + string(os.PathSeparator)
+	p := NewPipeline(Config{
+		InputDir:      dirname,
+		RelativeToDir: relativeToDir,
+		LeftDelim:     leftDelim,
+		RightDelim:    rightDelim,
+		Mode:          mode,
+	})
 
-	if mode == Production {
+	return p.processManifest(manifest)
+}
 
-		var files []string
+func (p *Pipeline) processManifest(r io.Reader) (template.FuncMap, error) {
 
-		scanner := bufio.NewScanner(manifest)
+	var manifest *Manifest
 
-		for scanner.Scan() {
+	if p.cfg.Mode == Production {
 
-			files = strings.SplitN(scanner.Text(), oldNewSeparator, 2)
-			mapped[strings.TrimLeft(strings.TrimLeft(files[0], dirname), "/")] = "/" + files[1]
+		var err error
+
+		if manifest, err = p.readManifest(r); err != nil {
+			return nil, err
 		}
 	}
 
-	return loadMapFuncs(dirname, mode, relativeToDir, leftDelim, rightDelim, mapped), nil
+	return p.loadMapFuncs(manifest), nil
 }
 
-func loadMapFuncs(dirname string, mode RunMode, relativeToDir bool, leftDelim string, rightDelim string, mapped map[string]string) template.FuncMap {
+func (p *Pipeline) loadMapFuncs(manifest *Manifest) template.FuncMap {
 
 	funcs := template.FuncMap{}
 
-	if mode == Production {
-		funcs[cssHTMLTag] = createProdCSSTemplateFunc(mapped)
-		funcs[jsHTMLTag] = createProdJSTemplateFunc(mapped)
+	if p.cfg.Mode == Production {
+		funcs[p.funcName(cssHTMLTag)] = p.createProdCSSTemplateFunc(manifest)
+		funcs[p.funcName(jsHTMLTag)] = p.createProdJSTemplateFunc(manifest)
+		funcs[p.funcName(assetURLFunc)] = p.createProdAssetURLFunc(manifest)
+
+		cache := &inlineCache{contents: map[string]string{}}
+		funcs[p.funcName(cssInlineFunc)] = p.createProdInlineFunc(manifest, cache, styleTag, cssTag)
+		funcs[p.funcName(jsInlineFunc)] = p.createProdInlineFunc(manifest, cache, inlineScriptTag, jsTag)
 
 		return funcs
 	}
 
-	funcs[cssHTMLTag] = createDevCSSTemplateFunc(dirname, relativeToDir, leftDelim, rightDelim)
-	funcs[jsHTMLTag] = createDevJSTemplateFunc(dirname, relativeToDir, leftDelim, rightDelim)
+	includes := &includeCache{entries: map[string]*includeEntry{}}
+	funcs[p.funcName(cssHTMLTag)] = p.createDevCSSTemplateFunc(includes)
+	funcs[p.funcName(jsHTMLTag)] = p.createDevJSTemplateFunc(includes)
+	funcs[p.funcName(assetURLFunc)] = p.createDevAssetURLFunc()
+	funcs[p.funcName(cssInlineFunc)] = p.createDevInlineFunc(styleTag)
+	funcs[p.funcName(jsInlineFunc)] = p.createDevInlineFunc(inlineScriptTag)
 
 	return funcs
 }
 
-func createProdCSSTemplateFunc(mapped map[string]string) interface{} {
-	return func(name string) template.HTML {
-		return template.HTML(fmt.Sprintf(cssTag, mapped[name]))
+// createProdAssetURLFunc returns the asset_url func, resolving the logical name of any file
+// to the URL of it's hashed file.
+func (p *Pipeline) createProdAssetURLFunc(manifest *Manifest) interface{} {
+	return func(name string) (string, error) {
+
+		a := manifest.Asset(name)
+		if a != nil {
+			return a.URL, nil
+		}
+
+		// a comment can't be rendered within an attribute
+		if p.cfg.Missing == MissingComment {
+			p.logf("assets: %s is missing from the manifest", name)
+			return "", nil
+		}
+
+		return p.missingURL(name)
 	}
 }
 
-func createProdJSTemplateFunc(mapped map[string]string) interface{} {
-	return func(name string) template.HTML {
-		return template.HTML(fmt.Sprintf(jsTag, mapped[name]))
+// createDevAssetURLFunc returns the asset_url func, resolving the logical name of any file
+// to the URL of the source file served by the DevHandler.
+func (p *Pipeline) createDevAssetURLFunc() interface{} {
+	return func(name string) (string, error) {
+		return p.devURL(name), nil
 	}
 }
 
-func createDevCSSTemplateFunc(dirname string, relativeToDir bool, leftDelim string, rightDelim string) interface{} {
-	// custom lexer, bytesBuffer
+func (p *Pipeline) createProdCSSTemplateFunc(manifest *Manifest) interface{} {
+	return func(name string, attrs ...string) (template.HTML, error) {
+		return p.prodTag(manifest, name, cssTag, attrs)
+	}
+}
 
-	return func(name string) template.HTML {
-		buff := new(bytes.Buffer)
+func (p *Pipeline) createProdJSTemplateFunc(manifest *Manifest) interface{} {
+	return func(name string, attrs ...string) (template.HTML, error) {
+		return p.prodTag(manifest, name, jsTag, attrs)
+	}
+}
 
-		files, err := loadFromDelims(dirname, name, relativeToDir, dirname, leftDelim, rightDelim)
-		if err != nil {
-			panic(err)
+// prodTag renders tag for the asset name, with the integrity attribute when SRI is enabled and
+// the manifest has recorded the asset's integrity. Missing assets are handled as per Config.Missing.
+func (p *Pipeline) prodTag(manifest *Manifest, name string, tag htmlTag, args []string) (template.HTML, error) {
+
+	attrs, err := parseTagAttrs(args)
+	if err != nil {
+		return "", err
+	}
+
+	a := manifest.Asset(name)
+	if a == nil {
+
+		if p.cfg.Missing == MissingComment {
+			p.logf("assets: %s is missing from the manifest", name)
+			return template.HTML("<!-- " + commentSafeHTML(name) + " is missing from the manifest -->"), nil
 		}
 
-		for _, file := range files {
-			buff.WriteString(fmt.Sprintf(cssTag, "/"+filepath.Clean(dirname)+file))
+		url, err := p.missingURL(name)
+		if err != nil {
+			return "", err
 		}
 
-		buff.WriteString(fmt.Sprintf(cssTag, "/"+dirname+name))
+		return tag.render(url, "", "", attrs), nil
+	}
 
-		return template.HTML(buff.String())
+	if p.cfg.SRI && a.Integrity != "" {
+		return tag.render(a.URL, a.Integrity, p.cfg.CrossOrigin, attrs), nil
 	}
+
+	return tag.render(a.URL, "", "", attrs), nil
 }
 
-func createDevJSTemplateFunc(dirname string, relativeToDir bool, leftDelim string, rightDelim string) interface{} {
-	// custom lexer, bytesBuffer
+// missingURL returns the URL of the unhashed file name, missing from the manifest, when
+// falling back to it or an error.
+func (p *Pipeline) missingURL(name string) (string, error) {
 
-	return func(name string) template.HTML {
-		buff := new(bytes.Buffer)
+	if p.cfg.Missing == MissingFallback {
+		return p.publicURL(p.cfg.BaseURL, path.Join(p.prefix, name)), nil
+	}
 
-		files, err := loadFromDelims(dirname, name, relativeToDir, dirname, leftDelim, rightDelim)
-		if err != nil {
-			panic(err)
-		}
+	return "", fmt.Errorf("%s is missing from the manifest", name)
+}
 
-		for _, file := range files {
-			buff.WriteString(fmt.Sprintf(jsTag, "/"+filepath.Clean(dirname)+file))
-		}
+func (p *Pipeline) createDevCSSTemplateFunc(includes *includeCache) interface{} {
+	return func(name string, attrs ...string) (template.HTML, error) {
+		return p.devTags(includes, name, cssTag, attrs)
+	}
+}
+
+func (p *Pipeline) createDevJSTemplateFunc(includes *includeCache) interface{} {
+	return func(name string, attrs ...string) (template.HTML, error) {
+		return p.devTags(includes, name, jsTag, attrs)
+	}
+}
+
+// devTags renders tag for the file name and, unless DevBundle is set, one for each of it's includes
+// before it, resolved using the includes cache.
+func (p *Pipeline) devTags(includes *includeCache, name string, tag htmlTag, args []string) (template.HTML, error) {
+
+	attrs, err := parseTagAttrs(args)
+	if err != nil {
+		return "", err
+	}
+
+	if p.cfg.DevBundle {
+		return tag.render(p.devBundleURL(name), "", "", attrs), nil
+	}
+
+	buff := new(bytes.Buffer)
+
+	files, err := p.cachedIncludes(includes, name)
+	if err != nil {
+		return "", err
+	}
 
-		buff.WriteString(fmt.Sprintf(jsTag, "/"+dirname+name))
+	for _, file := range files {
+		buff.WriteString(string(tag.render(p.devURL(file), "", "", attrs)))
+	}
+
+	buff.WriteString(string(tag.render(p.devURL(name), "", "", attrs)))
+
+	return template.HTML(buff.String()), nil
+}
 
-		return template.HTML(buff.String())
+// logf logs to the Config.ErrorLog or the log package's standard logger.
+func (p *Pipeline) logf(format string, args ...interface{}) {
+
+	if p.cfg.ErrorLog != nil {
+		p.cfg.ErrorLog.Printf(format, args...)
+		return
 	}
+
+	log.Printf(format, args...)
 }
 
-func loadFromDelims(dirname string, name string, relativeToDir bool, relativeDir string, leftDelim string, rightDelim string) ([]string, error) {
-	var err error
+// devURL returns the URL of the source file name in Development mode.
+func (p *Pipeline) devURL(name string) string {
+	return p.publicURL(p.cfg.DevBaseURL, path.Join(p.prefix, name))
+}
+
+// loadFromDelims returns the files included by name, recursively, in the order
+// they are required.
+func (p *Pipeline) loadFromDelims(name string) ([]string, error) {
+	return p.resolveIncludes(name, nil, nil)
+}
+
+// resolveIncludes returns the files included by name, stack holding the files
+// currently being resolved; the state of every file read is added to states, if not nil.
+func (p *Pipeline) resolveIncludes(name string, stack []string, states map[string]fileState) ([]string, error) {
 	var files []string
 	var ok bool
-	var path string
 
 	existing := map[string]struct{}{}
 
-	f, err := os.Open(dirname + name)
+	// the state is taken before reading so that a change in between invalidates the cached includes
+	if states != nil {
+
+		fi, err := fs.Stat(p.src, name)
+		if err != nil {
+			return nil, err
+		}
+
+		states[name] = fileState{modTime: fi.ModTime(), size: fi.Size()}
+	}
+
+	b, err := fs.ReadFile(p.src, name)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	l, err := bundler.NewLexer("assets-bundle", f, leftDelim, rightDelim)
+	l, err := bundler.NewLexer("assets-bundle", bytes.NewReader(b), p.cfg.LeftDelim, p.cfg.RightDelim)
 	if err != nil {
 		return nil, err
 	}
 
+	stack = append(stack, name)
+
 LOOP:
 	for {
 		itm := l.NextItem()
@@ -481,24 +806,29 @@ LOOP:
 
 		case bundler.ItemFile:
 
-			if relativeToDir {
-				path = filepath.FromSlash("/" + itm.Val)
-			} else {
-				path = filepath.FromSlash("/" + dirname + itm.Val)
-			}
+			include := p.includePath(name, itm.Val)
 
-			if _, ok = existing[path]; !ok {
-				files = append(files, path)
-				existing[path] = struct{}{}
+			if err = checkCycle(stack, include, name, b, itm); err != nil {
+				return nil, err
 			}
 
-			fls, err := loadFromDelims(dirname, itm.Val, relativeToDir, relativeDir, leftDelim, rightDelim)
+			fls, err := p.resolveIncludes(include, stack[:len(stack):len(stack)], states)
 			if err != nil {
 				return nil, err
 			}
 
 			// must prepend as the just processed files are requirements.
-			files = append(fls, files...)
+			for _, file := range fls {
+				if _, ok = existing[file]; !ok {
+					files = append(files, file)
+					existing[file] = struct{}{}
+				}
+			}
+
+			if _, ok = existing[include]; !ok {
+				files = append(files, include)
+				existing[include] = struct{}{}
+			}
 
 		case bundler.ItemEOF:
 			break LOOP
@@ -509,3 +839,14 @@ LOOP:
 
 	return files, nil
 }
+
+func contains(s []string, v string) bool {
+
+	for _, str := range s {
+		if str == v {
+			return true
+		}
+	}
+
+	return false
+}
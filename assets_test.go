@@ -1,9 +1,15 @@
 package assets
 
 import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
 	"os"
 	"testing"
+	"testing/fstest"
 
+	"github.com/go-playground/bundler"
 	. "gopkg.in/go-playground/assert.v1"
 )
 
@@ -25,7 +31,7 @@ func TestGenerate(t *testing.T) {
 	// func Generate(dirname string, outputDir string, relativeToDir bool, leftDelim string, rightDelim string, ignoreRegexp *regexp.Regexp) ([]*bundler.ProcessedFile, string, error) {
 	processed, manifest, err := Generate("testfiles/test1", "testfiles/test1output", false, "include(", ")", extensions)
 	Equal(t, err, nil)
-	Equal(t, manifest, "testfiles/test1output/testfiles/test1/manifest.txt")
+	Equal(t, manifest, "testfiles/test1output/testfiles/test1/manifest.json")
 	Equal(t, len(processed), 3)
 
 	// Equal(t, processed[0].OriginalFilename, "testfiles/test1/file1.txt")
@@ -34,7 +40,7 @@ func TestGenerate(t *testing.T) {
 
 	funcs, err := LoadManifestFiles("testfiles/test1output", Development, false, "include(", ")")
 	Equal(t, err, nil)
-	Equal(t, len(funcs), 2)
+	Equal(t, len(funcs), 5)
 
 	err = os.RemoveAll("testfiles/test1output")
 	Equal(t, err, nil)
@@ -50,7 +56,7 @@ func TestGenerateWithSymlinks(t *testing.T) {
 	// func Generate(dirname string, outputDir string, relativeToDir bool, leftDelim string, rightDelim string, ignoreRegexp *regexp.Regexp) ([]*bundler.ProcessedFile, string, error) {
 	processed, manifest, err := Generate("testfiles/test2", "testfiles/test2output", false, "include(", ")", extensions)
 	Equal(t, err, nil)
-	Equal(t, manifest, "testfiles/test2output/testfiles/test2/manifest.txt")
+	Equal(t, manifest, "testfiles/test2output/testfiles/test2/manifest.json")
 	Equal(t, len(processed), 5)
 
 	// Equal(t, processed[0].OriginalFilename, "testfiles/test2/f2.txt")
@@ -59,8 +65,134 @@ func TestGenerateWithSymlinks(t *testing.T) {
 
 	funcs, err := LoadManifestFiles("testfiles/test2output", Development, false, "include(", ")")
 	Equal(t, err, nil)
-	Equal(t, len(funcs), 2)
+	Equal(t, len(funcs), 5)
 
 	err = os.RemoveAll("testfiles/test2output")
 	Equal(t, err, nil)
 }
+
+func TestGenerateConcurrently(t *testing.T) {
+
+	in := fstest.MapFS{}
+
+	for i := 0; i < 100; i++ {
+		in[fmt.Sprintf("static/js/file%03d.js", i)] = &fstest.MapFile{Data: []byte(fmt.Sprintf("//include(js/shared.js)\nvar f%d = %d;", i, i))}
+	}
+
+	in["static/js/shared.js"] = &fstest.MapFile{Data: []byte("var shared = 1;")}
+
+	cfg := Config{
+		InputDir:      "static",
+		Input:         in,
+		RelativeToDir: true,
+		Extensions:    map[string]struct{}{".js": {}},
+		NoCache:       true,
+	}
+
+	var results [][]*bundler.ProcessedFile
+
+	for _, workers := range []int{1, 16} {
+
+		cfg.Output = mapWriteFS{MapFS: fstest.MapFS{}}
+		cfg.Workers = workers
+
+		processed, _, err := NewPipeline(cfg).Build()
+		Equal(t, err, nil)
+		Equal(t, len(processed), 101)
+		Equal(t, processed[0].OriginalFilename, "static/js/file000.js")
+		Equal(t, processed[100].OriginalFilename, "static/js/shared.js")
+
+		results = append(results, processed)
+	}
+
+	Equal(t, results[0], results[1])
+
+	// test BAD input
+	in["static/js/file050.js"] = &fstest.MapFile{Data: []byte("//include(js/missing.js)")}
+
+	_, _, err := NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "open js/missing.js: file does not exist")
+}
+
+func TestAssetURL(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `<img src="{{ asset_url "images/logo.png" }}">`), `<img src="/static/images/logo.png">`)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	cfg.Mode = Production
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `<img src="{{ asset_url "images/logo.png" }}">`), `<img src="`+manifest.URL("images/logo.png")+`">`)
+	Equal(t, renderTemplate(t, funcs, `{{ asset_url "js/app.js" }}`), manifest.URL("js/app.js"))
+}
+
+func TestMissingPolicy(t *testing.T) {
+
+	logs := new(bytes.Buffer)
+
+	cfg, _ := testConfig()
+
+	cfg.Mode = Production
+	cfg.ErrorLog = log.New(logs, "", 0)
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	execute := func(text string) (string, error) {
+
+		funcs, err := NewPipeline(cfg).FuncMap()
+		Equal(t, err, nil)
+
+		tpl, err := template.New("test").Funcs(funcs).Parse(text)
+		Equal(t, err, nil)
+
+		buff := new(bytes.Buffer)
+		err = tpl.Execute(buff, nil)
+
+		return buff.String(), err
+	}
+
+	_, err = execute(`{{ js_tag "missing.js" }}`)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), `template: test:1:3: executing "test" at <js_tag "missing.js">: error calling js_tag: missing.js is missing from the manifest`)
+
+	_, err = execute(`{{ asset_url "missing.png" }}`)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), `template: test:1:3: executing "test" at <asset_url "missing.png">: error calling asset_url: missing.png is missing from the manifest`)
+
+	cfg.Missing = MissingComment
+
+	html, err := execute(`{{ css_tag "missing--.css" }}<img src="{{ asset_url "missing.png" }}">`)
+	Equal(t, err, nil)
+	Equal(t, html, `<!-- missing-&#45;.css is missing from the manifest --><img src="">`)
+	Equal(t, logs.String(), "assets: missing--.css is missing from the manifest\nassets: missing.png is missing from the manifest\n")
+
+	cfg.Missing = MissingFallback
+
+	html, err = execute(`{{ js_tag "missing.js" "defer" }}<img src="{{ asset_url "missing.png" }}">`)
+	Equal(t, err, nil)
+	Equal(t, html, `<script type="text/javascript" src="/static/missing.js" defer></script><img src="/static/missing.png">`)
+
+	// present assets are unaffected
+	html, err = execute(`{{ js_tag "js/app.js" }}`)
+	Equal(t, err, nil)
+	MatchRegex(t, html, `src="/static/js/app-[0-9a-f]{32}\.js"`)
+
+	// development funcs return errors too
+	cfg.Mode = Development
+
+	_, err = execute(`{{ js_tag "missing.js" }}`)
+	NotEqual(t, err, nil)
+	MatchRegex(t, err.Error(), `error calling js_tag: open missing\.js: .*`)
+}
@@ -0,0 +1,283 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+const (
+	cacheFile    = "assets-cache.json"
+	cacheVersion = 2
+)
+
+// buildCache is written next to the manifest by Build, it records the content hashes of the
+// sources every output was created from so that unchanged files are reused by the next Build.
+type buildCache struct {
+	Version int                    `json:"version"`
+	Options string                 `json:"options"` // hash of the options affecting the output
+	Entries map[string]*cacheEntry `json:"entries"` // keyed by the file's logical name
+}
+
+type cacheEntry struct {
+	Sources map[string]string `json:"sources"` // content hashes of the file and all it's includes
+	Asset   *Asset            `json:"asset"`
+	Copied  bool              `json:"copied,omitempty"` // the file is also copied as is
+}
+
+// files returns the output of the file name.
+func (e *cacheEntry) files(p *Pipeline, name string) []string {
+
+	// written next to the source files the copy is the source file itself
+	if e.Copied && p.overlap != "." {
+		return append(e.Asset.files(), path.Join(p.prefix, name))
+	}
+
+	return e.Asset.files()
+}
+
+// cacheState is the build cache of a single Build.
+type cacheState struct {
+	prev *buildCache
+
+	mu   sync.Mutex // guards next and sums, files are processed concurrently
+	next *buildCache
+	sums map[string]string // content hashes of the sources read during this Build
+}
+
+// newCacheState returns the build cache for a Build, reading the previous cache from out;
+// the previous cache is ignored when it was created with different options.
+func (p *Pipeline) newCacheState(out WriteFS) *cacheState {
+
+	c := &cacheState{
+		next: &buildCache{Version: cacheVersion, Options: p.cacheOptions(), Entries: map[string]*cacheEntry{}},
+		sums: map[string]string{},
+	}
+
+	if p.cfg.NoCache {
+		return c
+	}
+
+	prev, err := p.readCache(out)
+	if err != nil || prev.Version != cacheVersion || prev.Options != c.next.Options {
+		return c
+	}
+
+	c.prev = prev
+
+	return c
+}
+
+// newUpdateCacheState returns the build cache for an Update, keeping the entries of the previous
+// cache as only the changed files are rebuilt.
+func (p *Pipeline) newUpdateCacheState(out WriteFS) *cacheState {
+
+	c := p.newCacheState(out)
+
+	if c.prev != nil {
+		for name, entry := range c.prev.Entries {
+			c.next.Entries[name] = entry
+		}
+	}
+
+	return c
+}
+
+// readCache reads the build cache written next to the manifest of fsys.
+func (p *Pipeline) readCache(fsys fs.FS) (*buildCache, error) {
+
+	b, err := fs.ReadFile(fsys, path.Join(p.prefix, cacheFile))
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(buildCache)
+
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// cacheOptions returns a hash of every option that affects the output of a file.
+func (p *Pipeline) cacheOptions() string {
+
+	h := sha256.New()
+
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%t\x00%s\x00%s\x00%s", cacheVersion, p.prefix, p.cfg.LeftDelim, p.cfg.RightDelim,
+		p.cfg.RelativeToDir, p.cfg.Hash, p.cfg.FilenamePattern, p.cfg.Integrity)
+
+	fmt.Fprintf(h, "\x00%t\x00%t\x00%s\x00%s\x00%s", p.cfg.SourceMaps, p.cfg.SourceMapOutput != nil, p.cfg.BaseURL,
+		p.cfg.DevBaseURL, p.cfg.StripPrefix)
+
+	// the extensions determine if a file is bundled or copied
+	extensions := make([]string, 0, len(p.cfg.Extensions))
+
+	for ext := range p.cfg.Extensions {
+		extensions = append(extensions, ext)
+	}
+
+	sort.Strings(extensions)
+
+	fmt.Fprintf(h, "\x00%q", extensions)
+
+	for _, c := range p.cfg.Compressors {
+		fmt.Fprintf(h, "\x00%T%+v\x00%s\x00%s", c, c, c.Encoding(), c.Extension())
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// sourceHash returns the hash the build cache records for the source contents b.
+func sourceHash(b []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// sum returns the content hash of the source file name.
+func (c *cacheState) sum(p *Pipeline, name string) (string, error) {
+
+	c.mu.Lock()
+	sum, ok := c.sums[name]
+	c.mu.Unlock()
+
+	if ok {
+		return sum, nil
+	}
+
+	b, err := fs.ReadFile(p.src, name)
+	if err != nil {
+		return "", err
+	}
+
+	sum = sourceHash(b)
+
+	c.mu.Lock()
+	c.sums[name] = sum
+	c.mu.Unlock()
+
+	return sum, nil
+}
+
+// lookup returns the cache entry of the file name when none of it's sources changed and
+// it's output still exists. Hits are carried over to the next cache.
+func (c *cacheState) lookup(p *Pipeline, out WriteFS, name string) (*cacheEntry, bool) {
+
+	if c == nil || c.prev == nil {
+		return nil, false
+	}
+
+	entry, ok := c.prev.Entries[name]
+	if !ok {
+		return nil, false
+	}
+
+	for source, sum := range entry.Sources {
+		if current, err := c.sum(p, source); err != nil || current != sum {
+			return nil, false
+		}
+	}
+
+	for _, file := range entry.files(p, name) {
+		if _, err := fs.Stat(out, file); err != nil {
+			return nil, false
+		}
+	}
+
+	c.mu.Lock()
+	c.next.Entries[name] = entry
+	c.mu.Unlock()
+
+	return entry, true
+}
+
+// store records the output of the file name.
+func (c *cacheState) store(name string, entry *cacheEntry) {
+
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for source, sum := range entry.Sources {
+		c.sums[source] = sum
+	}
+
+	c.next.Entries[name] = entry
+}
+
+// removeStale removes the output of the previous Build that is no longer produced,
+// files listed in the previous manifest and files copied as is.
+func (p *Pipeline) removeStale(out WriteFS, prev *Manifest, c *cacheState, assets []*Asset) {
+
+	current := map[string]bool{}
+
+	for _, a := range assets {
+		for _, file := range a.files() {
+			current[file] = true
+		}
+	}
+
+	for name, entry := range c.next.Entries {
+		for _, file := range entry.files(p, name) {
+			current[file] = true
+		}
+	}
+
+	// files are usually listed in both the previous manifest and cache
+	stale := map[string]bool{}
+
+	if prev != nil {
+		for _, a := range prev.Assets {
+			for _, file := range a.files() {
+				stale[file] = !current[file]
+			}
+		}
+	}
+
+	if c.prev != nil {
+		for name, entry := range c.prev.Entries {
+			for _, file := range entry.files(p, name) {
+				stale[file] = !current[file]
+			}
+		}
+	}
+
+	files := make([]string, 0, len(stale))
+
+	for file, ok := range stale {
+		if ok {
+			files = append(files, file)
+		}
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		fmt.Println("Removing Existing File:", file)
+		out.Remove(file)
+	}
+
+	out.Remove(path.Join(p.prefix, legacyManifestFile))
+}
+
+func (p *Pipeline) writeCache(out WriteFS, c *cacheState) error {
+
+	if p.cfg.NoCache {
+		out.Remove(path.Join(p.prefix, cacheFile))
+		return nil
+	}
+
+	b, err := json.Marshal(c.next)
+	if err != nil {
+		return err
+	}
+
+	return out.WriteFile(path.Join(p.prefix, cacheFile), b)
+}
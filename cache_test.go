@@ -0,0 +1,226 @@
+package assets
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+// recordingWriteFS records the names of the files written and removed.
+type recordingWriteFS struct {
+	mapWriteFS
+	written *[]string
+	removed *[]string
+}
+
+func (r recordingWriteFS) WriteFile(name string, data []byte) error {
+	mapWriteFSMu.Lock()
+	*r.written = append(*r.written, name)
+	mapWriteFSMu.Unlock()
+	return r.mapWriteFS.WriteFile(name, data)
+}
+
+func (r recordingWriteFS) Remove(name string) error {
+	mapWriteFSMu.Lock()
+	*r.removed = append(*r.removed, name)
+	mapWriteFSMu.Unlock()
+	return r.mapWriteFS.Remove(name)
+}
+
+func TestBuildCache(t *testing.T) {
+
+	in := fstest.MapFS{
+		"static/js/app.js":   {Data: []byte("//include(js/lib.js)\nvar app = 1;\n")},
+		"static/js/lib.js":   {Data: []byte("var lib = 1;\n")},
+		"static/js/other.js": {Data: []byte("var other = 1;\n")},
+		"static/logo.png":    {Data: []byte("PNG")},
+	}
+
+	var written, removed []string
+
+	out := recordingWriteFS{mapWriteFS: mapWriteFS{MapFS: fstest.MapFS{}}, written: &written, removed: &removed}
+
+	cfg := Config{
+		InputDir:      "static",
+		Input:         in,
+		Output:        out,
+		RelativeToDir: true,
+		Extensions:    map[string]struct{}{".js": {}},
+	}
+
+	build := func() []string {
+
+		written = nil
+		removed = nil
+
+		_, _, err := NewPipeline(cfg).Build()
+		Equal(t, err, nil)
+
+		sort.Strings(written)
+
+		return written
+	}
+
+	Equal(t, len(build()), 7)
+
+	_, ok := out.MapFS["static/assets-cache.json"]
+	Equal(t, ok, true)
+
+	first, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	// nothing changed, only the manifest and cache are rewritten
+	Equal(t, build(), []string{"static/assets-cache.json", "static/manifest.json"})
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	Equal(t, manifest, first)
+
+	// changing an include rebuilds the files including it
+	in["static/js/lib.js"] = &fstest.MapFile{Data: []byte("var lib = 2;\n")}
+
+	manifest, err = NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	written = build()
+	Equal(t, len(written), 4)
+	Equal(t, written[1], "static/js/app-"+hashOf(t, cfg, "js/app.js")+".js")
+	Equal(t, written[2], "static/js/lib-"+hashOf(t, cfg, "js/lib.js")+".js")
+
+	// the previous output is removed, once
+	Equal(t, removed, []string{manifest.Asset("js/app.js").File, manifest.Asset("js/lib.js").File, "static/manifest.txt"})
+
+	_, ok = out.MapFS[manifest.Asset("js/app.js").File]
+	Equal(t, ok, false)
+
+	_, ok = out.MapFS[manifest.Asset("js/other.js").File]
+	Equal(t, ok, true)
+
+	// missing output is recreated
+	logo := "static/logo-" + fmt.Sprintf("%x", md5.Sum([]byte("PNG"))) + ".png"
+
+	delete(out.MapFS, "static/logo.png")
+	Equal(t, build(), []string{"static/assets-cache.json", logo, "static/logo.png", "static/manifest.json"})
+
+	// removed copied files are removed from the output
+	delete(in, "static/logo.png")
+	Equal(t, len(build()), 2)
+
+	_, ok = out.MapFS["static/logo.png"]
+	Equal(t, ok, false)
+
+	_, ok = out.MapFS[logo]
+	Equal(t, ok, false)
+
+	// changed options rebuild everything
+	cfg.FilenamePattern = "[name].[hash:8].[ext]"
+	Equal(t, len(build()), 5)
+
+	cfg.DevBaseURL = "http://localhost:3000/"
+	Equal(t, len(build()), 5)
+
+	// a file copied with other extensions is bundled
+	cfg.Extensions = map[string]struct{}{".css": {}}
+	Equal(t, len(build()), 8)
+
+	cfg.Extensions = map[string]struct{}{".js": {}}
+	Equal(t, len(build()), 5)
+
+	manifest, err = NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	Equal(t, string(out.MapFS[manifest.Asset("js/other.js").File].Data), "var other=1;")
+
+	// disabled cache
+	cfg.NoCache = true
+	Equal(t, len(build()), 4)
+
+	_, ok = out.MapFS["static/assets-cache.json"]
+	Equal(t, ok, false)
+
+	// test BAD input
+	cfg.NoCache = false
+	out.MapFS["static/assets-cache.json"] = &fstest.MapFile{Data: []byte("{")}
+	Equal(t, len(build()), 5)
+}
+
+func hashOf(t *testing.T, cfg Config, name string) string {
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	return manifest.Asset(name).Hash
+}
+
+func TestOverlappingOutput(t *testing.T) {
+
+	for _, output := range []string{"", "static", "static/public"} {
+
+		t.Chdir(t.TempDir())
+
+		for name, data := range map[string]string{"js/app.js": "//include(js/lib.js)\nvar app = 1;\n", "js/lib.js": "var lib = 1;\n", "images/logo.png": "PNG"} {
+
+			err := os.MkdirAll(filepath.Join("static", filepath.Dir(name)), 0777)
+			Equal(t, err, nil)
+
+			err = os.WriteFile(filepath.Join("static", name), []byte(data), 0644)
+			Equal(t, err, nil)
+		}
+
+		cfg := Config{
+			InputDir:      "static",
+			OutputDir:     output,
+			LeftDelim:     "//include(",
+			RightDelim:    ")",
+			RelativeToDir: true,
+			Extensions:    map[string]struct{}{".js": {}},
+		}
+
+		build := func() []string {
+
+			_, _, err := NewPipeline(cfg).Build()
+			Equal(t, err, nil)
+
+			manifest, err := NewPipeline(cfg).Manifest()
+			Equal(t, err, nil)
+			Equal(t, manifest.Names(), []string{"images/logo.png", "js/app.js", "js/lib.js"})
+
+			var files []string
+
+			err = filepath.WalkDir(".", func(name string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					files = append(files, filepath.ToSlash(name))
+				}
+				return err
+			})
+			Equal(t, err, nil)
+
+			return files
+		}
+
+		// the output of the previous Build isn't processed as source files
+		files := build()
+		Equal(t, build(), files)
+
+		manifest, err := NewPipeline(cfg).Manifest()
+		Equal(t, err, nil)
+
+		app := manifest.Asset("js/app.js").File
+
+		err = os.WriteFile(filepath.Join("static", "js", "app.js"), []byte("//include(js/lib.js)\nvar app = 2;\n"), 0644)
+		Equal(t, err, nil)
+
+		next := build()
+		Equal(t, len(next), len(files))
+		Equal(t, contains(files, path.Join(cfg.OutputDir, app)), true)
+		Equal(t, contains(next, path.Join(cfg.OutputDir, app)), false)
+		Equal(t, contains(next, "static/images/logo.png"), true)
+	}
+}
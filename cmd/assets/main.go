@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-playground/assets"
 	"github.com/go-playground/bundler"
@@ -18,6 +23,20 @@ var (
 	flagRightDelim            = flag.String("rd", "", "The Right Delimiter for file includes")
 	flagIncludesRelativeToDir = flag.Bool("rtd", true, "Specifies if the files included should be treated as relative to the directory, or relative to the files from which they are included.")
 	flagProcessExtensions     = flag.String("extensions", ".js,.css", "Specifies a comma separated list of extensions of files to be processed. Deafult \".js,.css\"")
+	flagWatch                 = flag.Bool("watch", false, "Watches the -i option DIR for changes, after the initial build, rebuilding the files affected.")
+	flagWorkers               = flag.Int("workers", 0, "The number of files processed concurrently, if 0 the number of CPUs is used.")
+	flagWatchInterval         = flag.Duration("interval", 500*time.Millisecond, "The interval the -i option DIR is polled at for changes when using -watch.")
+	flagGraphFormat           = flag.String("format", "dot", "The output format of the graph command, dot or json.")
+	flagSourceMaps            = flag.Bool("sourcemaps", false, "Writes a source map next to every minified js and css file.")
+	flagSourceMapDir          = flag.String("sourcemap-dir", "", "Writes the source maps to DIR instead of the output directory, keeping them private.")
+	flagBaseURL               = flag.String("base-url", "", "The URL the processed files are served from i.e. https://cdn.example.com/static/, if blank \"/\" is used.")
+	flagTemplates             = flag.String("templates", "", "Comma separated glob patterns of the templates checked by the validate command i.e. \"templates/*.html\".")
+	flagStripPrefix           = flag.String("strip-prefix", "", "The path prefix removed from the processed files to create their URLs.")
+	flagGenGo                 = flag.String("gen-go", "", "Generates a Go file of package PKG embedding the output directory after the build, see -gen-go-file.")
+	flagGenGoFile             = flag.String("gen-go-file", "assets_gen.go", "The Go file written by -gen-go, the output directory must be within it's directory.")
+	flagGenConsts             = flag.String("gen-consts", "", "Generates a Go file of package PKG with a constant for every asset after the build, see -gen-consts-file.")
+	flagGenConstsFile         = flag.String("gen-consts-file", "assets_consts.go", "The Go file written by -gen-consts.")
+	flagFuncPrefix            = flag.String("func-prefix", "", "The prefix of the template func names i.e. admin_ for admin_css_tag, used by the validate command.")
 
 	input      string
 	output     string
@@ -34,9 +53,57 @@ var (
 )
 
 func main() {
-	parseFlags()
 
-	processed, manifest, err := assets.Generate(input, output, relativeToDir, leftDelim, rightDelim, extensions)
+	flag.Usage = usage
+
+	command, args := "", os.Args[1:]
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	parseFlags(args)
+
+	cfg := assets.Config{
+		InputDir:      input,
+		OutputDir:     output,
+		RelativeToDir: relativeToDir,
+		LeftDelim:     leftDelim,
+		RightDelim:    rightDelim,
+		Extensions:    extensions,
+		Workers:       *flagWorkers,
+		SourceMaps:    *flagSourceMaps || *flagSourceMapDir != "",
+		BaseURL:       *flagBaseURL,
+		StripPrefix:   *flagStripPrefix,
+		FuncPrefix:    *flagFuncPrefix,
+	}
+
+	if *flagSourceMapDir != "" {
+		cfg.SourceMapOutput = assets.DirFS(*flagSourceMapDir)
+	}
+
+	p := assets.NewPipeline(cfg)
+
+	switch command {
+	case "":
+		build(p)
+	case "graph":
+		graph(p)
+	case "validate":
+		validate(p)
+	default:
+		panic("** Unknown command " + command)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n  assets [command] [options]\n\nCommands:\n  graph\tPrints the include graph of the processed files, see -format.\n  validate\tReports the templates referencing files missing from the manifest, see -templates.\n\nOptions:\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func build(p *assets.Pipeline) {
+
+	processed, manifest, err := p.Build()
 	if err != nil {
 		panic(err)
 	}
@@ -45,6 +112,119 @@ func main() {
 
 	fmt.Println("\nManifest Generated:", manifest)
 	fmt.Printf("\n")
+
+	if *flagGenGo != "" {
+		genGo(p)
+	}
+
+	if *flagGenConsts != "" {
+		genConsts(p)
+	}
+
+	if *flagWatch {
+		watch(p)
+	}
+}
+
+func watch(p *assets.Pipeline) {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("Watching %s for changes, press Ctrl+C to stop\n\n", input)
+
+	err := p.Watch(ctx, *flagWatchInterval, func(r *assets.Rebuild) {
+
+		if r.Err != nil {
+			fmt.Printf("[%s] Rebuild of %s failed after %s: %s\n\n", time.Now().Format("15:04:05"), strings.Join(r.Changed, ", "), r.Duration, r.Err)
+			return
+		}
+
+		fmt.Printf("[%s] Rebuilt %d files in %s, changed: %s\n", time.Now().Format("15:04:05"), len(r.Processed), r.Duration, strings.Join(r.Changed, ", "))
+
+		for _, file := range r.Processed {
+			fmt.Println("  " + file.NewFilename)
+		}
+
+		fmt.Printf("\n")
+	})
+	if err != nil && err != context.Canceled {
+		panic(err)
+	}
+}
+
+func genGo(p *assets.Pipeline) {
+
+	buff := new(bytes.Buffer)
+
+	if err := p.GenerateGo(buff, *flagGenGo, filepath.Dir(*flagGenGoFile)); err != nil {
+		panic(err)
+	}
+
+	if err := os.WriteFile(*flagGenGoFile, buff.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Go File Generated:", *flagGenGoFile)
+	fmt.Printf("\n")
+}
+
+func genConsts(p *assets.Pipeline) {
+
+	buff := new(bytes.Buffer)
+
+	if err := p.GenerateConstants(buff, *flagGenConsts); err != nil {
+		panic(err)
+	}
+
+	if err := os.WriteFile(*flagGenConstsFile, buff.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Go Constants Generated:", *flagGenConstsFile)
+	fmt.Printf("\n")
+}
+
+func graph(p *assets.Pipeline) {
+
+	g, err := p.Graph()
+	if err != nil {
+		panic(err)
+	}
+
+	switch *flagGraphFormat {
+	case "dot":
+		err = g.WriteDOT(os.Stdout)
+	case "json":
+		err = g.WriteJSON(os.Stdout)
+	default:
+		panic("** Unknown graph format " + *flagGraphFormat + ", must be dot or json")
+	}
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func validate(p *assets.Pipeline) {
+
+	if *flagTemplates == "" {
+		panic("** No templates specified with -templates option")
+	}
+
+	report, err := p.ValidateTemplates(os.DirFS("."), strings.Split(*flagTemplates, ",")...)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range report.Unreferenced {
+		fmt.Println("Unreferenced:", name)
+	}
+
+	if err = report.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
 func printResults(processed []*bundler.ProcessedFile) {
@@ -56,9 +236,9 @@ func printResults(processed []*bundler.ProcessedFile) {
 	}
 }
 
-func parseFlags() {
+func parseFlags(args []string) {
 
-	flag.Parse()
+	flag.CommandLine.Parse(args)
 
 	input = strings.TrimSpace(*flagFileOrDir)
 	output = strings.TrimSpace(*flagOuputFile)
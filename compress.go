@@ -0,0 +1,91 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Compressor creates a pre-compressed variant of the processed files, written
+// alongside the hashed file, so that they never need to be compressed on the fly.
+type Compressor interface {
+
+	// Encoding returns the Content-Encoding of the variant i.e. "gzip" or "br".
+	Encoding() string
+
+	// Extension returns the extension appended to the hashed filename i.e. ".gz" or ".br".
+	Extension() string
+
+	// Compress writes the compressed b to w.
+	Compress(w io.Writer, b []byte) error
+}
+
+// Variant is a pre-compressed variant of an asset.
+type Variant struct {
+	File string `json:"file"` // compressed file within the output
+	Size int64  `json:"size"` // size in bytes of the compressed file
+}
+
+// Gzip returns a Compressor that creates ".gz" variants at the given compression level,
+// see the compress/gzip constants.
+func Gzip(level int) Compressor {
+	return gzipCompressor(level)
+}
+
+type gzipCompressor int
+
+func (gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (gzipCompressor) Extension() string {
+	return ".gz"
+}
+
+func (level gzipCompressor) Compress(w io.Writer, b []byte) error {
+
+	gz, err := gzip.NewWriterLevel(w, int(level))
+	if err != nil {
+		return err
+	}
+
+	if _, err = gz.Write(b); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// compress writes the variants of the hashed file name with contents b, variants that
+// aren't smaller than the original are skipped.
+func (p *Pipeline) compress(out WriteFS, name string, b []byte) (map[string]*Variant, error) {
+
+	var variants map[string]*Variant
+
+	for _, c := range p.cfg.Compressors {
+
+		buff := new(bytes.Buffer)
+
+		if err := c.Compress(buff, b); err != nil {
+			return nil, err
+		}
+
+		if buff.Len() >= len(b) {
+			continue
+		}
+
+		v := &Variant{File: name + c.Extension(), Size: int64(buff.Len())}
+
+		if err := out.WriteFile(v.File, buff.Bytes()); err != nil {
+			return nil, err
+		}
+
+		if variants == nil {
+			variants = map[string]*Variant{}
+		}
+
+		variants[c.Encoding()] = v
+	}
+
+	return variants, nil
+}
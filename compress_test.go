@@ -0,0 +1,86 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+// identityCompressor never beats the original size.
+type identityCompressor struct{}
+
+func (identityCompressor) Encoding() string  { return "identity" }
+func (identityCompressor) Extension() string { return ".id" }
+
+func (identityCompressor) Compress(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func TestCompressors(t *testing.T) {
+
+	out := mapWriteFS{MapFS: fstest.MapFS{}}
+
+	cfg := Config{
+		InputDir: "static",
+		Input: fstest.MapFS{
+			"static/big.js":   {Data: []byte("var a = \"" + strings.Repeat("a", 1024) + "\";")},
+			"static/small.js": {Data: []byte("var a;")},
+		},
+		Output:      out,
+		Extensions:  map[string]struct{}{".js": {}},
+		Compressors: []Compressor{Gzip(gzip.BestCompression), identityCompressor{}},
+	}
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("big.js")
+	Equal(t, len(a.Variants), 1)
+
+	v := a.Variants["gzip"]
+	NotEqual(t, v, nil)
+	Equal(t, v.File, a.File+".gz")
+
+	b, err := fs.ReadFile(out, v.File)
+	Equal(t, err, nil)
+	Equal(t, v.Size, int64(len(b)))
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	Equal(t, err, nil)
+
+	b, err = io.ReadAll(r)
+	Equal(t, err, nil)
+
+	orig, err := fs.ReadFile(out, a.File)
+	Equal(t, err, nil)
+	Equal(t, b, orig)
+
+	// gzip of a tiny file is larger than the original
+	Equal(t, len(manifest.Asset("small.js").Variants), 0)
+
+	// variants are removed on rebuild
+	cfg.Input = fstest.MapFS{"static/other.js": {Data: []byte("var b;")}}
+
+	_, _, err = NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	_, err = fs.Stat(out, v.File)
+	NotEqual(t, err, nil)
+
+	// test BAD input
+	cfg.Compressors = []Compressor{Gzip(42)}
+
+	_, _, err = NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "gzip: invalid compression level: 42")
+}
@@ -0,0 +1,168 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	gotoken "go/token"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// constantsType is the type of the generated constants.
+const constantsType = "Asset"
+
+var constantsTemplate = template.Must(template.New("constants").Parse(`// Code generated by assets -gen-consts; DO NOT EDIT.
+
+package {{ .Package }}
+
+// Asset is the logical name of an asset of the manifest, as accepted by the template funcs.
+type Asset string
+
+// The assets of the manifest.
+const (
+{{- range .Constants }}
+	{{ .Ident }} Asset = {{ printf "%q" .Name }}
+{{- end }}
+)
+
+type assetInfo struct {
+	url       string
+	integrity string
+}
+
+var assetInfos = map[Asset]assetInfo{
+{{- range .Constants }}
+	{{ .Ident }}: { {{- printf "%q" .Asset.URL }}, {{ printf "%q" .Asset.Integrity -}} },
+{{- end }}
+}
+
+// Name returns the logical name of the asset.
+func (a Asset) Name() string {
+	return string(a)
+}
+
+// URL returns the URL of the hashed file.
+func (a Asset) URL() string {
+	return assetInfos[a].url
+}
+
+// Integrity returns the Subresource Integrity value of the hashed file, blank if not recorded.
+func (a Asset) Integrity() string {
+	return assetInfos[a].integrity
+}
+`))
+
+type assetConstant struct {
+	Ident string
+	Name  string
+	Asset *Asset
+}
+
+// GenerateConstants writes the Go source file of package pkg with a constant of the Asset type, i.e. AppJS
+// for "js/app.js", for every asset of the manifest created by Build, see the GenerateConstants func.
+func (p *Pipeline) GenerateConstants(w io.Writer, pkg string) error {
+
+	manifest, err := p.Manifest()
+	if err != nil {
+		return err
+	}
+
+	return GenerateConstants(w, pkg, manifest)
+}
+
+// GenerateConstants writes the Go source file of package pkg with a constant of the Asset type for every asset
+// of manifest, so that the names used from Go code are checked by the compiler; their URL and Integrity methods
+// return the values recorded in the manifest. The constants are named after the filename, i.e. AppJS for
+// "js/app.js", or the whole path, i.e. AdminAppJS for "admin/app.js", when several files share the same name.
+func GenerateConstants(w io.Writer, pkg string, manifest *Manifest) error {
+
+	if !gotoken.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name %q", pkg)
+	}
+
+	names := manifest.Names()
+	idents := make(map[string][]string, len(names))
+
+	for _, name := range names {
+		ident := constantName(path.Base(name))
+		idents[ident] = append(idents[ident], name)
+	}
+
+	constants := make([]assetConstant, 0, len(names))
+	seen := map[string]string{constantsType: ""}
+
+	for _, name := range names {
+
+		ident := constantName(path.Base(name))
+
+		if _, ok := seen[ident]; ok || len(idents[ident]) > 1 {
+			ident = constantName(name)
+		}
+
+		if other, ok := seen[ident]; ok {
+
+			if other == "" {
+				return fmt.Errorf("%s can't be named %s, it's the name of the type", name, ident)
+			}
+
+			return fmt.Errorf("%s and %s have the same constant name %s", other, name, ident)
+		}
+
+		seen[ident] = name
+		constants = append(constants, assetConstant{Ident: ident, Name: name, Asset: manifest.Asset(name)})
+	}
+
+	buff := new(bytes.Buffer)
+
+	err := constantsTemplate.Execute(buff, map[string]interface{}{
+		"Package":   pkg,
+		"Constants": constants,
+	})
+	if err != nil {
+		return err
+	}
+
+	b, err := format.Source(buff.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+// constantName returns the exported identifier of the file name, made up of it's words, with the
+// extension in upper case i.e. "css/site-theme.css" becomes CssSiteThemeCSS.
+func constantName(name string) string {
+
+	ext := path.Ext(name)
+	words := strings.FieldsFunc(strings.TrimSuffix(name, ext), isNotIdentRune)
+
+	var sb strings.Builder
+
+	for _, word := range words {
+		r := []rune(word)
+		sb.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+
+	for _, word := range strings.FieldsFunc(ext, isNotIdentRune) {
+		sb.WriteString(strings.ToUpper(word))
+	}
+
+	ident := sb.String()
+
+	if !gotoken.IsExported(ident) {
+		ident = constantsType + ident
+	}
+
+	return ident
+}
+
+func isNotIdentRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,90 @@
+package assets
+
+import (
+	"bytes"
+	"go/parser"
+	gotoken "go/token"
+	"strings"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestGenerateConstants(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	buff := new(bytes.Buffer)
+
+	err = NewPipeline(cfg).GenerateConstants(buff, "static")
+	Equal(t, err, nil)
+
+	src := buff.String()
+
+	f, err := parser.ParseFile(gotoken.NewFileSet(), "assets_consts.go", src, 0)
+	Equal(t, err, nil)
+	Equal(t, f.Name.Name, "static")
+
+	Equal(t, strings.HasPrefix(src, "// Code generated by assets -gen-consts; DO NOT EDIT.\n"), true)
+
+	for ident, name := range map[string]string{"AppJS": "js/app.js", "LibJS": "js/lib.js", "UtilJS": "js/util.js", "SiteCSS": "css/site.css", "LogoPNG": "images/logo.png"} {
+
+		a := manifest.Asset(name)
+
+		MatchRegex(t, src, `\n\t`+ident+` +Asset = "`+name+`"\n`)
+		MatchRegex(t, src, `\n\t`+ident+`: +\{"`+a.URL+`", "`+strings.Replace(a.Integrity, "+", `\+`, -1)+`"\},\n`)
+	}
+
+	// files sharing the same name are named after their path
+	manifest = &Manifest{Assets: map[string]*Asset{
+		"js/app.js":        {Name: "js/app.js"},
+		"admin/app.js":     {Name: "admin/app.js"},
+		"images/404.png":   {Name: "images/404.png"},
+		"css/site.min.css": {Name: "css/site.min.css"},
+	}}
+
+	buff.Reset()
+
+	err = GenerateConstants(buff, "static", manifest)
+	Equal(t, err, nil)
+
+	for _, constant := range []string{`AdminAppJS +Asset = "admin/app.js"`, `JsAppJS +Asset = "js/app.js"`, `Asset404PNG +Asset = "images/404.png"`, `SiteMinCSS +Asset = "css/site.min.css"`} {
+		MatchRegex(t, buff.String(), constant)
+	}
+
+	Equal(t, constantName("css/site-theme.css"), "CssSiteThemeCSS")
+	Equal(t, constantName("fonts/ícones_v2.woff2"), "FontsÍconesV2WOFF2")
+	Equal(t, constantName("LICENSE"), "LICENSE")
+
+	// a file named after the type is named after it's path
+	manifest.Assets = map[string]*Asset{"images/asset": {}}
+
+	buff.Reset()
+
+	err = GenerateConstants(buff, "static", manifest)
+	Equal(t, err, nil)
+	MatchRegex(t, buff.String(), `ImagesAsset Asset = "images/asset"`)
+
+	// test BAD input
+	err = GenerateConstants(buff, "static-assets", manifest)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), `invalid package name "static-assets"`)
+
+	manifest.Assets = map[string]*Asset{"js/app-a.js": {}, "js/app_a.js": {}}
+
+	err = GenerateConstants(buff, "static", manifest)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "js/app-a.js and js/app_a.js have the same constant name JsAppAJS")
+
+	manifest.Assets = map[string]*Asset{"asset": {}}
+
+	err = GenerateConstants(buff, "static", manifest)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "asset can't be named Asset, it's the name of the type")
+}
@@ -0,0 +1,48 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/bundler"
+)
+
+// CycleError is returned when files include each other in a cycle.
+type CycleError struct {
+	Cycle []string // the files of the cycle, starting and ending with the same file
+	File  string   // the file containing the include directive closing the cycle
+	Line  int      // the line of the include directive
+}
+
+// Error returns the cycle i.e. "js/b.js:3: include cycle js/a.js -> js/b.js -> js/a.js"
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("%s:%d: include cycle %s", e.File, e.Line, strings.Join(e.Cycle, " -> "))
+}
+
+// checkCycle returns a *CycleError when include is already being processed, stack holding the
+// files currently being processed with name, the file containing itm, as the last one.
+func checkCycle(stack []string, include string, name string, b []byte, itm bundler.Item) error {
+
+	for i, file := range stack {
+		if file == include {
+
+			cycle := make([]string, 0, len(stack)-i+1)
+			cycle = append(append(cycle, stack[i:]...), include)
+
+			return &CycleError{Cycle: cycle, File: name, Line: lineOf(b, int(itm.Pos))}
+		}
+	}
+
+	return nil
+}
+
+// lineOf returns the 1 based line number of the byte offset pos within b.
+func lineOf(b []byte, pos int) int {
+
+	if pos > len(b) {
+		pos = len(b)
+	}
+
+	return bytes.Count(b[:pos], []byte{'\n'}) + 1
+}
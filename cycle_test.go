@@ -0,0 +1,75 @@
+package assets
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestIncludeCycles(t *testing.T) {
+
+	cfg := Config{
+		InputDir: "static",
+		Input: fstest.MapFS{
+			"static/a.js":    {Data: []byte("//include(b.js)\nvar a = 1;\n")},
+			"static/b.js":    {Data: []byte("var b = 1;\n\n//include(c.js)\n")},
+			"static/c.js":    {Data: []byte("//include(d.js)\n//include(a.js)\n")},
+			"static/d.js":    {Data: []byte("var d = 1;\n")},
+			"static/self.js": {Data: []byte("var self = 1;\n//include(self.js)")},
+		},
+		Output:     mapWriteFS{MapFS: fstest.MapFS{}},
+		Extensions: map[string]struct{}{".js": {}},
+		Workers:    1,
+	}
+
+	// build
+	_, _, err := NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "c.js:2: include cycle a.js -> b.js -> c.js -> a.js")
+
+	var cycleErr *CycleError
+
+	Equal(t, errors.As(err, &cycleErr), true)
+	Equal(t, cycleErr.Cycle, []string{"a.js", "b.js", "c.js", "a.js"})
+	Equal(t, cycleErr.File, "c.js")
+	Equal(t, cycleErr.Line, 2)
+
+	// development
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	tpl, err := template.New("test").Funcs(funcs).Parse(`{{ js_tag "b.js" }}`)
+	Equal(t, err, nil)
+
+	err = tpl.Execute(io.Discard, nil)
+	NotEqual(t, err, nil)
+	MatchRegex(t, err.Error(), `a\.js:1: include cycle b\.js -> c\.js -> a\.js -> b\.js$`)
+
+	p := NewPipeline(cfg)
+
+	_, err = p.loadFromDelims("b.js")
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "a.js:1: include cycle b.js -> c.js -> a.js -> b.js")
+
+	_, err = p.loadFromDelims("self.js")
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "self.js:2: include cycle self.js -> self.js")
+
+	// the same file included more than once isn't a cycle
+	cfg.Input = fstest.MapFS{
+		"static/a.js": {Data: []byte("//include(b.js)\n//include(c.js)\n")},
+		"static/b.js": {Data: []byte("//include(c.js)\n")},
+		"static/c.js": {Data: []byte("var c = 1;\n")},
+	}
+
+	files, err := NewPipeline(cfg).loadFromDelims("a.js")
+	Equal(t, err, nil)
+	Equal(t, files, []string{"c.js", "b.js"})
+
+	_, _, err = NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+}
@@ -0,0 +1,116 @@
+package assets
+
+import (
+	"bytes"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// bundleQuery is the query parameter requesting the bundle of a file from the DevHandler.
+const bundleQuery = "bundle"
+
+// DevHandler returns an http.Handler, for use in Development mode, serving the source files
+// at the same URLs the Development css_tag and js_tag funcs point to. When the "bundle" query
+// parameter is present, as emitted with Config.DevBundle, files with a processed extension are
+// bundled on the fly, unminified and with comments marking where every included file begins and ends.
+func (p *Pipeline) DevHandler() http.Handler {
+	return http.HandlerFunc(p.serveDev)
+}
+
+func (p *Pipeline) serveDev(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := p.devName(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	fi, err := fs.Stat(p.src, name)
+	if err != nil || fi.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	ext := path.Ext(name)
+	_, process := p.cfg.Extensions[ext]
+
+	if ctype := mime.TypeByExtension(ext); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if !process || !r.URL.Query().Has(bundleQuery) {
+
+		b, err := fs.ReadFile(p.src, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.ServeContent(w, r, name, fi.ModTime(), bytes.NewReader(b))
+		return
+	}
+
+	buff := new(bytes.Buffer)
+
+	// the css references are rewritten as the bundle is served from the URL of the entry file
+	if err = p.bundle(buff, name, &bundleState{comments: true, rewrite: true, dev: true}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(buff.Bytes()))
+}
+
+// devName returns the name within the input of the URL path produced by devURL.
+func (p *Pipeline) devName(urlPath string) (string, bool) {
+
+	base := "/"
+
+	if u, err := url.Parse(p.cfg.DevBaseURL); err == nil {
+		base = baseURL(u.Path)
+	}
+
+	if !strings.HasPrefix(urlPath, base) {
+		return "", false
+	}
+
+	name := p.cfg.StripPrefix + urlPath[len(base):]
+
+	if p.prefix != "." {
+
+		if !strings.HasPrefix(name, p.prefix+"/") {
+			return "", false
+		}
+
+		name = name[len(p.prefix)+1:]
+	}
+
+	if !fs.ValidPath(name) || name == "." {
+		return "", false
+	}
+
+	return name, true
+}
+
+// devBundleURL returns the URL of the bundle of the source file name served by the DevHandler.
+func (p *Pipeline) devBundleURL(name string) string {
+	return p.devURL(name) + "?" + bundleQuery
+}
+
+// commentSafe makes name safe to write within a /* */ comment.
+func commentSafe(name string) string {
+	return strings.Replace(name, "*/", "*\\/", -1)
+}
@@ -0,0 +1,118 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestDevHandler(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	cfg.DevBundle = true
+
+	p := NewPipeline(cfg)
+
+	funcs, err := p.FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="/static/js/app.js?bundle"></script>`)
+	Equal(t, renderTemplate(t, funcs, `{{ css_tag "css/site.css" }}`), `<link type="text/css" rel="stylesheet" href="/static/css/site.css?bundle">`)
+
+	h := p.DevHandler()
+
+	serve := func(method string, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w := serve(http.MethodGet, "/static/js/app.js?bundle")
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Header().Get("Cache-Control"), "no-cache")
+	MatchRegex(t, w.Header().Get("Content-Type"), "javascript")
+	Equal(t, w.Body.String(), "\n/* begin js/lib.js */\n"+
+		"\n/* begin js/util.js */\n"+
+		"var util = 1;\n"+
+		"\n/* end js/util.js */\n"+
+		"\nvar lib = 1;\n"+
+		"\n/* end js/lib.js */\n"+
+		"\nvar app = 1;\n")
+
+	// source files are served as is
+	w = serve(http.MethodGet, "/static/js/app.js")
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.String(), "//include(js/lib.js)\nvar app = 1;\n")
+
+	w = serve(http.MethodGet, "/static/images/logo.png?bundle")
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.String(), "PNG")
+
+	w = serve(http.MethodHead, "/static/js/app.js?bundle")
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.Len(), 0)
+
+	// test BAD input
+	w = serve(http.MethodGet, "/static/js/missing.js?bundle")
+	Equal(t, w.Code, http.StatusNotFound)
+
+	w = serve(http.MethodGet, "/other/js/app.js")
+	Equal(t, w.Code, http.StatusNotFound)
+
+	w = serve(http.MethodGet, "/static/js")
+	Equal(t, w.Code, http.StatusNotFound)
+
+	w = serve(http.MethodPost, "/static/js/app.js")
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+
+	p = NewPipeline(Config{
+		InputDir:   "static",
+		Input:      mapFSInput,
+		Extensions: cfg.Extensions,
+	})
+
+	w = httptest.NewRecorder()
+	p.DevHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/js/app.js?bundle", nil))
+	Equal(t, w.Code, http.StatusInternalServerError)
+}
+
+func TestDevHandlerCSSReferences(t *testing.T) {
+
+	in := fstest.MapFS{
+		"static/css/site.css":            {Data: []byte("/*include(vendor/lib/lib.css)*/\nbody { color: red; }\n")},
+		"static/vendor/lib/lib.css":      {Data: []byte(".icon { background: url(img/icon.png); }\n")},
+		"static/vendor/lib/img/icon.png": {Data: []byte("PNG")},
+	}
+
+	p := NewPipeline(Config{
+		InputDir:      "static",
+		Input:         in,
+		LeftDelim:     "/*include(",
+		RightDelim:    ")*/",
+		RelativeToDir: true,
+		Extensions:    map[string]struct{}{".css": {}},
+		DevBaseURL:    "http://localhost:3000/",
+	})
+
+	w := httptest.NewRecorder()
+	p.DevHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/css/site.css?bundle", nil))
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, strings.Contains(w.Body.String(), ".icon { background: url(http://localhost:3000/static/vendor/lib/img/icon.png); }"), true)
+
+	// source files are served as is
+	w = httptest.NewRecorder()
+	p.DevHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/vendor/lib/lib.css", nil))
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.String(), ".icon { background: url(img/icon.png); }\n")
+
+	// test BAD input
+	in["static/vendor/lib/lib.css"] = &fstest.MapFile{Data: []byte(".icon { background: url(img/missing.png); }\n")}
+
+	w = httptest.NewRecorder()
+	p.DevHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/css/site.css?bundle", nil))
+	Equal(t, w.Code, http.StatusInternalServerError)
+}
@@ -0,0 +1,92 @@
+package assets
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFS is a filesystem the processed assets and manifest can be written to by Build.
+// Names are slash separated paths as used by io/fs. Build writes files concurrently so
+// implementations must be safe for concurrent use.
+type WriteFS interface {
+	fs.FS
+	WriteFile(name string, data []byte) error
+	Remove(name string) error
+}
+
+// DirFS returns a WriteFS for the directory tree rooted at dir on disk;
+// symlinks within the tree are followed and files are replaced atomically.
+func DirFS(dir string) WriteFS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+func (dir dirFS) Open(name string) (fs.File, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return os.Open(dir.join(name))
+}
+
+func (dir dirFS) Stat(name string) (fs.FileInfo, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return os.Stat(dir.join(name))
+}
+
+func (dir dirFS) WriteFile(name string, data []byte) error {
+
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	p := dir.join(name)
+
+	if err := os.MkdirAll(filepath.Dir(p), os.FileMode(0777)); err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it so readers never see a partially written file
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Chmod(0644)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+func (dir dirFS) Remove(name string) error {
+
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return os.Remove(dir.join(name))
+}
+
+func (dir dirFS) join(name string) string {
+	return filepath.Join(string(dir), filepath.FromSlash(name))
+}
@@ -0,0 +1,182 @@
+package assets
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"sort"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+// mapWriteFS is an in memory WriteFS used for testing.
+type mapWriteFS struct {
+	MapFS fstest.MapFS
+}
+
+// mapWriteFSMu guards all mapWriteFS as Build writes concurrently.
+var mapWriteFSMu sync.Mutex
+
+func (m mapWriteFS) Open(name string) (fs.File, error) {
+	mapWriteFSMu.Lock()
+	defer mapWriteFSMu.Unlock()
+	return m.MapFS.Open(name)
+}
+
+func (m mapWriteFS) WriteFile(name string, data []byte) error {
+	mapWriteFSMu.Lock()
+	defer mapWriteFSMu.Unlock()
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: 0644}
+	return nil
+}
+
+func (m mapWriteFS) Remove(name string) error {
+	mapWriteFSMu.Lock()
+	defer mapWriteFSMu.Unlock()
+	if _, ok := m.MapFS[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, name)
+	return nil
+}
+
+var mapFSInput = fstest.MapFS{
+	"static/js/app.js":       {Data: []byte("//include(js/lib.js)\nvar app = 1;\n")},
+	"static/js/lib.js":       {Data: []byte("//include(js/util.js)\nvar lib = 1;\n")},
+	"static/js/util.js":      {Data: []byte("var util = 1;\n")},
+	"static/css/site.css":    {Data: []byte("body {\n  color: red;\n}\n")},
+	"static/images/logo.png": {Data: []byte("PNG")},
+}
+
+// testConfig returns the Config building mapFSInput into the returned, empty, mapWriteFS.
+func testConfig() (Config, mapWriteFS) {
+
+	out := mapWriteFS{MapFS: fstest.MapFS{}}
+
+	cfg := Config{
+		InputDir:      "static",
+		Input:         mapFSInput,
+		Output:        out,
+		RelativeToDir: true,
+		Extensions:    map[string]struct{}{".js": {}, ".css": {}},
+	}
+
+	return cfg, out
+}
+
+func renderTemplate(t *testing.T, funcs template.FuncMap, text string) string {
+
+	tpl, err := template.New("test").Funcs(funcs).Parse(text)
+	Equal(t, err, nil)
+
+	buff := new(bytes.Buffer)
+	err = tpl.Execute(buff, nil)
+	Equal(t, err, nil)
+
+	return buff.String()
+}
+
+func TestBuildFromFS(t *testing.T) {
+
+	cfg, out := testConfig()
+
+	processed, manifest, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+	Equal(t, manifest, "static/manifest.json")
+	Equal(t, len(processed), 5)
+	Equal(t, processed[0].OriginalFilename, "static/css/site.css")
+	Equal(t, processed[1].OriginalFilename, "static/images/logo.png")
+	Equal(t, processed[2].OriginalFilename, "static/js/app.js")
+
+	prev := processed[2].NewFilename
+
+	b, err := fs.ReadFile(out, prev)
+	Equal(t, err, nil)
+	Equal(t, string(b), "var util=1;var lib=1;var app=1;")
+
+	b, err = fs.ReadFile(out, "static/images/logo.png")
+	Equal(t, err, nil)
+	Equal(t, string(b), "PNG")
+
+	cfg.Mode = Production
+
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="/`+prev+`"></script>`)
+
+	// rebuilding removes the previous output
+	in := fstest.MapFS{}
+
+	for name, file := range mapFSInput {
+		in[name] = file
+	}
+
+	in["static/js/app.js"] = &fstest.MapFile{Data: []byte("//include(js/lib.js)\nvar app = 2;\n")}
+	cfg.Input = in
+
+	processed, _, err = NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	_, err = fs.Stat(out, prev)
+	NotEqual(t, err, nil)
+
+	current := []string{"static/assets-cache.json", "static/images/logo.png", "static/manifest.json"}
+
+	for _, file := range processed {
+		current = append(current, file.NewFilename)
+	}
+
+	files := make([]string, 0, len(out.MapFS))
+
+	for name := range out.MapFS {
+		files = append(files, name)
+	}
+
+	sort.Strings(current)
+	sort.Strings(files)
+	Equal(t, files, current)
+
+	// output filesystem must be writable
+	cfg.Output = fstest.MapFS{}
+	_, _, err = NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "output filesystem is not writable")
+}
+
+func TestDevelopmentFromFS(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	cfg.Mode = Development
+
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	s := renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`)
+	Equal(t, s, `<script type="text/javascript" src="/static/js/util.js"></script>`+
+		`<script type="text/javascript" src="/static/js/lib.js"></script>`+
+		`<script type="text/javascript" src="/static/js/app.js"></script>`)
+
+	// includes relative to the including file
+	cfg.RelativeToDir = false
+	cfg.Input = fstest.MapFS{
+		"static/js/app.js": {Data: []byte("//include(lib.js)")},
+		"static/js/lib.js": {Data: []byte("var lib = 1;")},
+	}
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	s = renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`)
+	Equal(t, s, `<script type="text/javascript" src="/static/js/lib.js"></script>`+
+		`<script type="text/javascript" src="/static/js/app.js"></script>`)
+
+	// test BAD input
+	cfg.InputDir = "../static"
+
+	_, err = NewPipeline(cfg).FuncMap()
+	NotEqual(t, err, nil)
+}
@@ -0,0 +1,84 @@
+package assets
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+)
+
+var (
+	// funcNames are the default names of the template funcs.
+	funcNames = []string{cssHTMLTag, jsHTMLTag, assetURLFunc, cssInlineFunc, jsInlineFunc}
+
+	funcNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// funcName returns the name of the template func registered under it's default name,
+// renamed by Config.FuncNames and prefixed by Config.FuncPrefix.
+func (p *Pipeline) funcName(name string) string {
+
+	if n, ok := p.cfg.FuncNames[name]; ok {
+		name = n
+	}
+
+	return p.cfg.FuncPrefix + name
+}
+
+// validFuncNames returns an error if the configured func names aren't valid
+// template identifiers, rename an unknown func or clash with one another.
+func (p *Pipeline) validFuncNames() error {
+
+	for name := range p.cfg.FuncNames {
+		if !contains(funcNames, name) {
+			return fmt.Errorf("invalid func name %q, must be one of css_tag, js_tag, asset_url, css_inline or js_inline", name)
+		}
+	}
+
+	seen := map[string]string{}
+
+	for _, name := range funcNames {
+
+		n := p.funcName(name)
+
+		if !funcNameRegex.MatchString(n) {
+			return fmt.Errorf("invalid name %q of the %s func", n, name)
+		}
+
+		if other, ok := seen[n]; ok {
+			return fmt.Errorf("invalid name %q of the %s func, already the name of the %s func", n, name, other)
+		}
+
+		seen[n] = name
+	}
+
+	return nil
+}
+
+// MergeFuncMaps merges the FuncMaps, i.e. of Pipelines with different FuncPrefix's, into a
+// single template.FuncMap; an error is returned if a func name is defined more than once.
+func MergeFuncMaps(funcMaps ...template.FuncMap) (template.FuncMap, error) {
+
+	merged := template.FuncMap{}
+
+	var dupes []string
+
+	for _, funcs := range funcMaps {
+		for name, fn := range funcs {
+
+			if _, ok := merged[name]; ok {
+				dupes = append(dupes, name)
+				continue
+			}
+
+			merged[name] = fn
+		}
+	}
+
+	if len(dupes) > 0 {
+		sort.Strings(dupes)
+		return nil, fmt.Errorf("func %q is defined more than once", dupes[0])
+	}
+
+	return merged, nil
+}
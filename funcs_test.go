@@ -0,0 +1,90 @@
+package assets
+
+import (
+	"html/template"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestFuncNames(t *testing.T) {
+
+	out := mapWriteFS{MapFS: fstest.MapFS{}}
+
+	in := fstest.MapFS{
+		"public/js/app.js":   {Data: []byte("var app = 1;\n")},
+		"admin/js/app.js":    {Data: []byte("var admin = 1;\n")},
+		"admin/css/site.css": {Data: []byte("body { color: red; }\n")},
+	}
+
+	public := Config{
+		InputDir:   "public",
+		Input:      in,
+		Output:     out,
+		Extensions: map[string]struct{}{".js": {}, ".css": {}},
+		Mode:       Production,
+	}
+
+	admin := public
+	admin.InputDir = "admin"
+	admin.FuncPrefix = "admin_"
+	admin.FuncNames = map[string]string{cssHTMLTag: "stylesheet"}
+
+	_, _, err := NewPipeline(public).Build()
+	Equal(t, err, nil)
+
+	_, _, err = NewPipeline(admin).Build()
+	Equal(t, err, nil)
+
+	publicFuncs, err := NewPipeline(public).FuncMap()
+	Equal(t, err, nil)
+
+	adminFuncs, err := NewPipeline(admin).FuncMap()
+	Equal(t, err, nil)
+
+	for _, name := range []string{"admin_stylesheet", "admin_js_tag", "admin_asset_url", "admin_css_inline", "admin_js_inline"} {
+		NotEqual(t, adminFuncs[name], nil)
+	}
+
+	funcs, err := MergeFuncMaps(publicFuncs, adminFuncs, template.FuncMap{"custom": func() string { return "custom" }})
+	Equal(t, err, nil)
+	Equal(t, len(funcs), 11)
+
+	publicManifest, err := NewPipeline(public).Manifest()
+	Equal(t, err, nil)
+
+	adminManifest, err := NewPipeline(admin).Manifest()
+	Equal(t, err, nil)
+
+	Equal(t, renderTemplate(t, funcs, `{{ asset_url "js/app.js" }} {{ admin_asset_url "js/app.js" }} {{ custom }}`),
+		publicManifest.URL("js/app.js")+" "+adminManifest.URL("js/app.js")+" custom")
+	Equal(t, renderTemplate(t, funcs, `{{ admin_stylesheet "css/site.css" }}`),
+		`<link type="text/css" rel="stylesheet" href="`+adminManifest.URL("css/site.css")+`">`)
+
+	// the Pipeline validates the templates using it's func names
+	templates := fstest.MapFS{
+		"index.html": {Data: []byte(`{{ admin_stylesheet "css/missing.css" }}{{ css_tag "css/missing.css" }}{{ admin_js_tag "js/app.js" }}`)},
+	}
+
+	report, err := NewPipeline(admin).ValidateTemplates(templates, "index.html")
+	Equal(t, err, nil)
+	Equal(t, report.Missing, []TemplateRef{{File: "index.html", Line: 1, Func: "admin_stylesheet", Name: "css/missing.css"}})
+	Equal(t, report.Unreferenced, []string{"css/site.css"})
+
+	// test BAD input
+	_, err = MergeFuncMaps(publicFuncs, publicFuncs)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), `func "asset_url" is defined more than once`)
+
+	for names, msg := range map[*Config]string{
+		{FuncNames: map[string]string{"link_tag": "link"}}:  `invalid func name "link_tag", must be one of css_tag, js_tag, asset_url, css_inline or js_inline`,
+		{FuncNames: map[string]string{jsHTMLTag: "js-tag"}}: `invalid name "js-tag" of the js_tag func`,
+		{FuncPrefix: "1_"}: `invalid name "1_css_tag" of the css_tag func`,
+		{FuncNames: map[string]string{cssHTMLTag: "tag", jsHTMLTag: "tag"}}: `invalid name "tag" of the js_tag func, already the name of the css_tag func`,
+	} {
+		_, err = NewPipeline(*names).FuncMap()
+		NotEqual(t, err, nil)
+		Equal(t, err.Error(), msg)
+	}
+}
@@ -0,0 +1,230 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	gotoken "go/token"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var goFileTemplate = template.Must(template.New("go").Parse(`// Code generated by assets -gen-go; DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+
+	"github.com/go-playground/assets"
+)
+
+{{ range .Embed }}//go:embed {{ . }}
+{{ end }}var files embed.FS
+
+// Manifest contains the URL of every embedded asset keyed by it's logical name.
+var Manifest = map[string]string{
+{{ range .Assets }}	{{ printf "%q" .Name }}: {{ printf "%q" .URL }},
+{{ end }}}
+
+var pipeline = assets.NewPipeline(assets.Config{
+{{ range .Config }}	{{ . }},
+{{ end }}})
+
+// output returns the embedded files rooted at the output directory.
+func output() fs.FS {
+{{- if eq .Dir "." }}
+	return files
+{{- else }}
+	sub, err := fs.Sub(files, {{ printf "%q" .Dir }})
+	if err != nil {
+		panic(err)
+	}
+
+	return sub
+{{- end }}
+}
+
+// FuncMap returns the Production template funcs of the embedded assets.
+func FuncMap() (template.FuncMap, error) {
+	return pipeline.FuncMap()
+}
+
+// Handler returns the Handler serving the embedded assets.
+func Handler() (*assets.Handler, error) {
+	return pipeline.Handler()
+}
+`))
+
+// GenerateGo writes the Go source file of package pkg embedding the Build output, using a go:embed
+// directive listing the manifest and every file it references, along with a map of the asset URLs and
+// the FuncMap and Handler funcs of a Production Pipeline configured the same way. dir is the directory
+// of the package, the OutputDir must be within it as files outside of it can't be embedded.
+func (p *Pipeline) GenerateGo(w io.Writer, pkg string, dir string) error {
+
+	if p.err != nil {
+		return p.err
+	}
+
+	if !gotoken.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name %q", pkg)
+	}
+
+	if p.cfg.Output != nil {
+		return errors.New("the Output filesystem can't be embedded, use the OutputDir")
+	}
+
+	embedDir, err := embedDir(dir, p.cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := p.Manifest()
+	if err != nil {
+		return err
+	}
+
+	files := []string{p.manifestName()}
+
+	for _, name := range manifest.Names() {
+		files = append(files, manifest.Asset(name).files()...)
+	}
+
+	patterns := make([]string, len(files))
+
+	for i, file := range files {
+		if patterns[i], err = embedPattern(path.Join(embedDir, file)); err != nil {
+			return err
+		}
+	}
+
+	sort.Strings(patterns)
+
+	list := make([]*Asset, 0, len(manifest.Assets))
+
+	for _, name := range manifest.Names() {
+		list = append(list, manifest.Asset(name))
+	}
+
+	buff := new(bytes.Buffer)
+
+	err = goFileTemplate.Execute(buff, map[string]interface{}{
+		"Package": pkg,
+		"Embed":   patterns,
+		"Assets":  list,
+		"Config":  p.goConfig(),
+		"Dir":     embedDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	b, err := format.Source(buff.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+// embedDir returns the slash separated path of the output dir relative to the package dir.
+func embedDir(dir string, output string) (string, error) {
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	absOutput, err := filepath.Abs(output)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absDir, absOutput)
+	if err != nil {
+		return "", err
+	}
+
+	if rel = filepath.ToSlash(rel); rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("the OutputDir %s isn't within the package directory %s", output, dir)
+	}
+
+	return rel, nil
+}
+
+// embedPattern returns the go:embed pattern matching file only, quoted when it contains spaces or quotes.
+func embedPattern(file string) (string, error) {
+
+	if strings.ContainsAny(file, "*?[\\") {
+		return "", fmt.Errorf("%s can't be embedded, it contains a pattern character", file)
+	}
+
+	if strings.ContainsAny(file, " \t\"'`") {
+		return strconv.Quote(file), nil
+	}
+
+	return file, nil
+}
+
+// goConfig returns the fields of the assets.Config, as Go source, of a Production Pipeline
+// serving the embedded output the same way this one does.
+func (p *Pipeline) goConfig() []string {
+
+	fields := []string{
+		"InputDir: " + strconv.Quote(p.prefix),
+		"Output: output()",
+		"Mode: assets.Production",
+	}
+
+	if p.cfg.SRI {
+		fields = append(fields, "SRI: true", "CrossOrigin: "+strconv.Quote(p.cfg.CrossOrigin))
+	}
+
+	if p.cfg.BaseURL != "/" {
+		fields = append(fields, "BaseURL: "+strconv.Quote(p.cfg.BaseURL))
+	}
+
+	if p.cfg.StripPrefix != "" {
+		fields = append(fields, "StripPrefix: "+strconv.Quote(p.cfg.StripPrefix))
+	}
+
+	switch p.cfg.Missing {
+	case MissingComment:
+		fields = append(fields, "Missing: assets.MissingComment")
+	case MissingFallback:
+		fields = append(fields, "Missing: assets.MissingFallback")
+	}
+
+	if p.cfg.FuncPrefix != "" {
+		fields = append(fields, "FuncPrefix: "+strconv.Quote(p.cfg.FuncPrefix))
+	}
+
+	if len(p.cfg.FuncNames) > 0 {
+
+		names := make([]string, 0, len(p.cfg.FuncNames))
+
+		for name := range p.cfg.FuncNames {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for i, name := range names {
+			names[i] = strconv.Quote(name) + ": " + strconv.Quote(p.cfg.FuncNames[name])
+		}
+
+		fields = append(fields, "FuncNames: map[string]string{"+strings.Join(names, ", ")+"}")
+	}
+
+	return fields
+}
@@ -0,0 +1,99 @@
+package assets
+
+import (
+	"bytes"
+	"go/parser"
+	gotoken "go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestGenerateGo(t *testing.T) {
+
+	dir := t.TempDir()
+
+	cfg, out := testConfig()
+
+	cfg.Output = nil
+	cfg.OutputDir = filepath.Join(dir, "public")
+	cfg.SRI = true
+	cfg.Missing = MissingFallback
+	cfg.FuncNames = map[string]string{jsHTMLTag: "script", cssHTMLTag: "stylesheet"}
+
+	p := NewPipeline(cfg)
+
+	_, _, err := p.Build()
+	Equal(t, err, nil)
+
+	manifest, err := p.Manifest()
+	Equal(t, err, nil)
+
+	buff := new(bytes.Buffer)
+
+	err = p.GenerateGo(buff, "web", dir)
+	Equal(t, err, nil)
+
+	src := buff.String()
+
+	f, err := parser.ParseFile(gotoken.NewFileSet(), "assets_gen.go", src, parser.ParseComments)
+	Equal(t, err, nil)
+	Equal(t, f.Name.Name, "web")
+
+	Equal(t, strings.HasPrefix(src, "// Code generated by assets -gen-go; DO NOT EDIT.\n"), true)
+	Equal(t, strings.Contains(src, "//go:embed public/static/manifest.json\n"), true)
+
+	for _, name := range manifest.Names() {
+
+		a := manifest.Asset(name)
+
+		Equal(t, strings.Contains(src, "//go:embed public/"+a.File+"\n"), true)
+		Equal(t, strings.Contains(src, `"`+name+`": `), true)
+		Equal(t, strings.Contains(src, `"`+a.URL+`",`), true)
+	}
+
+	for _, field := range []string{
+		`InputDir:    "static",`,
+		`Output:      output(),`,
+		`Mode:        assets.Production,`,
+		`SRI:         true,`,
+		`CrossOrigin: "anonymous",`,
+		`Missing:     assets.MissingFallback,`,
+		`FuncNames:   map[string]string{"css_tag": "stylesheet", "js_tag": "script"},`,
+		`sub, err := fs.Sub(files, "public")`,
+	} {
+		Equal(t, strings.Contains(src, field), true)
+	}
+
+	// the output within the package directory itself
+	buff.Reset()
+
+	err = p.GenerateGo(buff, "public", cfg.OutputDir)
+	Equal(t, err, nil)
+	Equal(t, strings.Contains(buff.String(), "//go:embed static/manifest.json\n"), true)
+	Equal(t, strings.Contains(buff.String(), "func output() fs.FS {\n\treturn files\n}"), true)
+
+	// test BAD input
+	err = p.GenerateGo(buff, "my-web", dir)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), `invalid package name "my-web"`)
+
+	err = p.GenerateGo(buff, "web", filepath.Join(dir, "web"))
+	NotEqual(t, err, nil)
+	MatchRegex(t, err.Error(), `^the OutputDir .* isn't within the package directory .*web$`)
+
+	cfg.Output = out
+
+	err = NewPipeline(cfg).GenerateGo(buff, "web", dir)
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "the Output filesystem can't be embedded, use the OutputDir")
+
+	_, err = embedPattern("static/images/a[1].png")
+	NotEqual(t, err, nil)
+
+	pattern, err := embedPattern("static/images/a b.png")
+	Equal(t, err, nil)
+	Equal(t, pattern, `"static/images/a b.png"`)
+}
@@ -0,0 +1,125 @@
+package assets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+)
+
+// Graph contains the include relationships of the processed files, all names are
+// relative to the InputDir.
+type Graph struct {
+
+	// Entries contains the transitive includes of every processed file, in the
+	// order they are first encountered while bundling.
+	Entries map[string][]string `json:"entries"`
+
+	// IncludedBy is the reverse index of Entries, the sorted processed files that
+	// include each file directly or transitively.
+	IncludedBy map[string][]string `json:"included_by"`
+
+	// Includes contains the files directly included by each file, in the order
+	// they are included.
+	Includes map[string][]string `json:"includes"`
+}
+
+// Graph resolves the includes of every processed file within the InputDir
+// and returns their relationships.
+func (p *Pipeline) Graph() (*Graph, error) {
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	g := &Graph{
+		Entries:    map[string][]string{},
+		IncludedBy: map[string][]string{},
+		Includes:   map[string][]string{},
+	}
+
+	err := p.walkFiles(".", func(name string, _ fs.FileInfo) error {
+
+		if !p.isProcessed(name) {
+			return nil
+		}
+
+		state := &bundleState{edges: g.Includes}
+
+		if err := p.bundle(io.Discard, name, state); err != nil {
+			return err
+		}
+
+		g.Entries[name] = state.includes
+
+		if g.Entries[name] == nil {
+			g.Entries[name] = []string{}
+		}
+
+		for _, include := range state.includes {
+			g.IncludedBy[include] = append(g.IncludedBy[include], name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entries := range g.IncludedBy {
+		sort.Strings(entries)
+	}
+
+	return g, nil
+}
+
+// WriteJSON writes the Graph to w as indented JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the direct includes of the Graph to w in the Graphviz DOT format,
+// processed files are drawn as boxes.
+func (g *Graph) WriteDOT(w io.Writer) error {
+
+	if _, err := io.WriteString(w, "digraph assets {\n\trankdir=LR;\n\tnode [shape=ellipse];\n"); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(g.Entries) {
+		if _, err := fmt.Fprintf(w, "\t%s [shape=box];\n", strconv.Quote(name)); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(g.Includes) {
+		for _, include := range g.Includes[name] {
+			if _, err := fmt.Fprintf(w, "\t%s -> %s;\n", strconv.Quote(name), strconv.Quote(include)); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, "}\n")
+
+	return err
+}
+
+func sortedKeys(m map[string][]string) []string {
+
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
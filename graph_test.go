@@ -0,0 +1,67 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestGraph(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	g, err := NewPipeline(cfg).Graph()
+	Equal(t, err, nil)
+
+	Equal(t, g.Entries, map[string][]string{
+		"css/site.css": {},
+		"js/app.js":    {"js/lib.js", "js/util.js"},
+		"js/lib.js":    {"js/util.js"},
+		"js/util.js":   {},
+	})
+
+	Equal(t, g.IncludedBy, map[string][]string{
+		"js/lib.js":  {"js/app.js"},
+		"js/util.js": {"js/app.js", "js/lib.js"},
+	})
+
+	Equal(t, g.Includes, map[string][]string{
+		"js/app.js": {"js/lib.js"},
+		"js/lib.js": {"js/util.js"},
+	})
+
+	buff := new(bytes.Buffer)
+
+	err = g.WriteDOT(buff)
+	Equal(t, err, nil)
+	Equal(t, buff.String(), `digraph assets {
+	rankdir=LR;
+	node [shape=ellipse];
+	"css/site.css" [shape=box];
+	"js/app.js" [shape=box];
+	"js/lib.js" [shape=box];
+	"js/util.js" [shape=box];
+	"js/app.js" -> "js/lib.js";
+	"js/lib.js" -> "js/util.js";
+}
+`)
+
+	buff.Reset()
+
+	err = g.WriteJSON(buff)
+	Equal(t, err, nil)
+
+	decoded := new(Graph)
+
+	err = json.Unmarshal(buff.Bytes(), decoded)
+	Equal(t, err, nil)
+	Equal(t, decoded, g)
+
+	// test BAD input
+	cfg.RelativeToDir = false
+
+	_, err = NewPipeline(cfg).Graph()
+	NotEqual(t, err, nil)
+}
@@ -0,0 +1,221 @@
+package assets
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// encodingPreference is the order pre-compressed variants are chosen in when the
+// client accepts several of them equally.
+var encodingPreference = []string{"br", "zstd", "gzip"}
+
+// Handler is an http.Handler serving the files listed in a Manifest, and only those,
+// out of the Build output. Hashed files are served with far future cache headers and
+// pre-compressed variants are negotiated using the Accept-Encoding header.
+type Handler struct {
+	fsys  fs.FS
+	files map[string]*Asset // keyed by the path of the asset's URL
+	maps  map[string]*Asset // keyed by the path of the source map's URL
+}
+
+// NewHandler returns a Handler serving the assets of manifest from fsys, the Build output;
+// the request path is the path of the asset's URL, mount it using http.StripPrefix when
+// served below another path.
+func NewHandler(fsys fs.FS, manifest *Manifest) *Handler {
+
+	h := &Handler{
+		fsys:  fsys,
+		files: make(map[string]*Asset, len(manifest.Assets)),
+		maps:  map[string]*Asset{},
+	}
+
+	for _, a := range manifest.Assets {
+
+		h.files[urlPath(a.URL)] = a
+
+		if a.SourceMapURL != "" {
+			h.maps[urlPath(a.SourceMapURL)] = a
+		}
+	}
+
+	return h
+}
+
+// Handler returns a Handler for the manifest created by Build.
+func (p *Pipeline) Handler() (*Handler, error) {
+
+	manifest, err := p.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHandler(p.out, manifest), nil
+}
+
+// ServeHTTP serves the requested asset, supporting HEAD, Range and conditional requests.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	a, ok := h.files[r.URL.Path]
+	if !ok {
+		if a, ok = h.maps[r.URL.Path]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := a.File
+	etag := a.Hash
+	mimeType := a.MIMEType
+
+	if r.URL.Path == urlPath(a.SourceMapURL) {
+		file = a.SourceMap
+		etag += "-map"
+		mimeType = "application/json"
+	} else if len(a.Variants) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), a.Variants); encoding != "" {
+			file = a.Variants[encoding].File
+			etag += "-" + encoding
+
+			w.Header().Set("Content-Encoding", encoding)
+		}
+	}
+
+	content, err := h.open(file)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if c, ok := content.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	if mimeType != "" {
+		w.Header().Set("Content-Type", mimeType)
+	}
+
+	if etag != "" {
+		w.Header().Set("ETag", strconv.Quote(etag))
+	}
+
+	w.Header().Set("Cache-Control", immutableCacheControl)
+
+	http.ServeContent(w, r, file, time.Time{}, content)
+}
+
+// urlPath returns the path of the URL u.
+func urlPath(u string) string {
+
+	if pu, err := url.Parse(u); err == nil {
+		return pu.Path
+	}
+
+	return u
+}
+
+// open returns the file as an io.ReadSeeker, reading it into memory when the
+// filesystem's files are not seekable.
+func (h *Handler) open(name string) (io.ReadSeeker, error) {
+
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// negotiateEncoding returns the Content-Encoding of the variant best matching the
+// Accept-Encoding header or blank when the original should be served.
+func negotiateEncoding(header string, variants map[string]*Variant) string {
+
+	if header == "" {
+		return ""
+	}
+
+	accepted := map[string]float64{}
+
+	for _, part := range strings.Split(header, ",") {
+
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		q := 1.0
+
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			v, err := strconv.ParseFloat(params[2:], 64)
+			if err != nil {
+				continue
+			}
+			q = v
+		}
+
+		accepted[encoding] = q
+	}
+
+	encodings := make([]string, 0, len(variants))
+
+	for encoding := range variants {
+		encodings = append(encodings, encoding)
+	}
+
+	sort.Slice(encodings, func(i, j int) bool {
+		return preference(encodings[i]) < preference(encodings[j]) ||
+			preference(encodings[i]) == preference(encodings[j]) && encodings[i] < encodings[j]
+	})
+
+	var best string
+	var bestQ float64
+
+	for _, encoding := range encodings {
+
+		q, ok := accepted[encoding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+func preference(encoding string) int {
+
+	for i, e := range encodingPreference {
+		if e == encoding {
+			return i
+		}
+	}
+
+	return len(encodingPreference)
+}
@@ -0,0 +1,161 @@
+package assets
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestHandler(t *testing.T) {
+
+	out := mapWriteFS{MapFS: fstest.MapFS{}}
+
+	cfg := Config{
+		InputDir: "static",
+		Input: fstest.MapFS{
+			"static/app.js":   {Data: []byte("var a = \"" + strings.Repeat("a", 1024) + "\";")},
+			"static/logo.png": {Data: []byte("PNG")},
+		},
+		Output:      out,
+		Extensions:  map[string]struct{}{".js": {}},
+		Compressors: []Compressor{Gzip(gzip.BestCompression)},
+		Mode:        Production,
+	}
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	h, err := NewPipeline(cfg).Handler()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("app.js")
+	orig := out.MapFS[a.File].Data
+
+	serve := func(method string, target string, headers ...string) *httptest.ResponseRecorder {
+
+		r := httptest.NewRequest(method, target, nil)
+
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := serve(http.MethodGet, a.URL)
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.Bytes(), orig)
+	Equal(t, w.Header().Get("Cache-Control"), "public, max-age=31536000, immutable")
+	Equal(t, w.Header().Get("ETag"), `"`+a.Hash+`"`)
+	Equal(t, w.Header().Get("Vary"), "Accept-Encoding")
+	Equal(t, w.Header().Get("Content-Encoding"), "")
+	MatchRegex(t, w.Header().Get("Content-Type"), "javascript")
+
+	// pre-compressed variant
+	w = serve(http.MethodGet, a.URL, "Accept-Encoding", "deflate, gzip;q=0.8")
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Header().Get("Content-Encoding"), "gzip")
+	Equal(t, w.Header().Get("ETag"), `"`+a.Hash+`-gzip"`)
+
+	r, err := gzip.NewReader(w.Body)
+	Equal(t, err, nil)
+
+	b, err := io.ReadAll(r)
+	Equal(t, err, nil)
+	Equal(t, b, orig)
+
+	w = serve(http.MethodGet, a.URL, "Accept-Encoding", "gzip;q=0")
+	Equal(t, w.Header().Get("Content-Encoding"), "")
+
+	// conditional, HEAD and Range requests
+	w = serve(http.MethodGet, a.URL, "If-None-Match", `"`+a.Hash+`"`)
+	Equal(t, w.Code, http.StatusNotModified)
+
+	w = serve(http.MethodHead, a.URL)
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.Len(), 0)
+
+	w = serve(http.MethodGet, a.URL, "Range", "bytes=0-3")
+	Equal(t, w.Code, http.StatusPartialContent)
+	Equal(t, w.Body.String(), string(orig[:4]))
+
+	// only files listed in the manifest are served
+	w = serve(http.MethodGet, "/static/app.js")
+	Equal(t, w.Code, http.StatusNotFound)
+
+	w = serve(http.MethodGet, "/static/manifest.json")
+	Equal(t, w.Code, http.StatusNotFound)
+
+	w = serve(http.MethodPost, a.URL)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+
+	// test BAD input
+	cfg.Output = fstest.MapFS{}
+
+	_, err = NewPipeline(cfg).Handler()
+	NotEqual(t, err, nil)
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+
+	variants := map[string]*Variant{"gzip": {}, "br": {}}
+
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"GZIP, deflate", "gzip"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"br;q=0, gzip;q=0.1", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, br;q=0", "gzip"},
+		{"gzip;q=abc", ""},
+	}
+
+	for _, tt := range tests {
+		Equal(t, negotiateEncoding(tt.header, variants), tt.expected)
+	}
+}
+
+func TestHandlerSourceMap(t *testing.T) {
+
+	cfg, out := testConfig()
+
+	cfg.Extensions = map[string]struct{}{".js": {}}
+	cfg.SourceMaps = true
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	h, err := NewPipeline(cfg).Handler()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("js/app.js")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, a.SourceMapURL, nil))
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.Bytes(), out.MapFS[a.SourceMap].Data)
+	Equal(t, w.Header().Get("Content-Type"), "application/json")
+	Equal(t, w.Header().Get("ETag"), `"`+a.Hash+`-map"`)
+	Equal(t, w.Header().Get("Vary"), "")
+}
@@ -0,0 +1,136 @@
+package assets
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultHash            = "md5"
+	defaultFilenamePattern = "[name]-[hash].[ext]"
+)
+
+var contentHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"fnv":    func() hash.Hash { return fnv.New64a() },
+}
+
+var filenamePatternRegex = regexp.MustCompile(`\[(name|ext|hash)(?::(\d+))?\]`)
+
+// validHash returns an error if alg is not a supported content hash algorithm.
+func validHash(alg string) error {
+
+	if _, ok := contentHashes[alg]; !ok {
+		return fmt.Errorf("invalid hash algorithm %q, must be one of md5, sha1, sha256 or fnv", alg)
+	}
+
+	return nil
+}
+
+// validFilenamePattern returns an error if pattern does not contain the hash or contains
+// an invalid placeholder.
+func validFilenamePattern(pattern string, alg string) error {
+
+	var hasHash bool
+
+	size := contentHashes[alg]().Size() * 2
+
+	for _, match := range filenamePatternRegex.FindAllStringSubmatch(pattern, -1) {
+
+		if match[2] == "" {
+			hasHash = hasHash || match[1] == "hash"
+			continue
+		}
+
+		if match[1] != "hash" {
+			return fmt.Errorf("invalid filename pattern %q, only [hash] accepts a length", pattern)
+		}
+
+		n, err := strconv.Atoi(match[2])
+		if err != nil || n < 1 || n > size {
+			return fmt.Errorf("invalid filename pattern %q, hash length must be between 1 and %d", pattern, size)
+		}
+
+		hasHash = true
+	}
+
+	if !hasHash {
+		return fmt.Errorf("invalid filename pattern %q, must contain [hash]", pattern)
+	}
+
+	if strings.HasPrefix(pattern, "/") || strings.Contains(pattern, "..") {
+		return fmt.Errorf("invalid filename pattern %q, must be relative to the file's directory", pattern)
+	}
+
+	return nil
+}
+
+// contentHash returns the hex encoded hash of b.
+func (p *Pipeline) contentHash(b []byte) string {
+
+	h := contentHashes[p.cfg.Hash]()
+	h.Write(b)
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// hashedName returns the output name, within the output, of the file name with the
+// provided extension and content hash.
+func (p *Pipeline) hashedName(name string, extension string, hash string) string {
+
+	dir, filename := path.Split(name)
+
+	filename = filenamePatternRegex.ReplaceAllStringFunc(p.cfg.FilenamePattern, func(s string) string {
+
+		match := filenamePatternRegex.FindStringSubmatch(s)
+
+		switch match[1] {
+		case "name":
+			return strings.TrimSuffix(filename, extension)
+		case "ext":
+			return strings.TrimPrefix(extension, ".")
+		}
+
+		if match[2] != "" {
+			n, _ := strconv.Atoi(match[2])
+			return hash[:n]
+		}
+
+		return hash
+	})
+
+	if extension == "" {
+		filename = strings.TrimSuffix(filename, ".")
+	}
+
+	return path.Join(p.prefix, dir, filename)
+}
+
+// checkCollisions returns an error when two assets with different contents were given
+// the same hashed name, which can happen when the hash is truncated.
+func checkCollisions(assets []*Asset) error {
+
+	files := make(map[string]*Asset, len(assets))
+
+	for _, a := range assets {
+
+		if existing, ok := files[a.File]; ok && existing.Hash != a.Hash {
+			return errors.New("hashed filename " + a.File + " of " + a.Name + " collides with " + existing.Name + ", use a longer hash in the filename pattern")
+		}
+
+		files[a.File] = a
+	}
+
+	return nil
+}
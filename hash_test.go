@@ -0,0 +1,95 @@
+package assets
+
+import (
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestHashAndFilenamePattern(t *testing.T) {
+
+	cfg, out := testConfig()
+
+	cfg.Hash = "sha256"
+	cfg.FilenamePattern = "[name].[hash:8].[ext]"
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	Equal(t, manifest.Hash, "sha256")
+
+	a := manifest.Asset("js/app.js")
+	Equal(t, len(a.Hash), 64)
+	Equal(t, a.File, "static/js/app."+a.Hash[:8]+".js")
+
+	_, ok := out.MapFS[a.File]
+	Equal(t, ok, true)
+
+	cfg.Mode = Production
+
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="/static/js/app.`+a.Hash[:8]+`.js"></script>`)
+
+	// hash as a directory
+	cfg.Hash = "fnv"
+	cfg.FilenamePattern = "[hash]/[name].[ext]"
+
+	_, _, err = NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err = NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	a = manifest.Asset("css/site.css")
+	Equal(t, len(a.Hash), 16)
+	Equal(t, a.File, "static/css/"+a.Hash+"/site.css")
+
+	// collisions of truncated hashes
+	cfg.Input = fstest.MapFS{
+		"static/a.js": {Data: []byte("var a = 1;")},
+		"static/b.js": {Data: []byte("var b = 1;")},
+	}
+	cfg.FilenamePattern = "[hash:1].[ext]"
+	cfg.Hash = "sha1"
+
+	var collided bool
+
+	// with a single hex character a collision is guaranteed once there are more than 16 files
+	for i := 0; i < 16 && !collided; i++ {
+
+		cfg.Input.(fstest.MapFS)["static/c"+string(rune('a'+i))+".js"] = &fstest.MapFile{Data: []byte{byte(i)}}
+
+		_, _, err = NewPipeline(cfg).Build()
+		collided = err != nil
+	}
+
+	Equal(t, collided, true)
+	MatchRegex(t, err.Error(), "^hashed filename static/[0-9a-f]\\.js of .* collides with .*, use a longer hash in the filename pattern$")
+
+	// test BAD input
+	tests := []struct {
+		hash    string
+		pattern string
+		err     string
+	}{
+		{"md4", "", `invalid hash algorithm "md4", must be one of md5, sha1, sha256 or fnv`},
+		{"", "[name].[ext]", `invalid filename pattern "[name].[ext]", must contain [hash]`},
+		{"", "[name:2].[hash].[ext]", `invalid filename pattern "[name:2].[hash].[ext]", only [hash] accepts a length`},
+		{"fnv", "[hash:17].[ext]", `invalid filename pattern "[hash:17].[ext]", hash length must be between 1 and 16`},
+		{"", "../[hash].[ext]", `invalid filename pattern "../[hash].[ext]", must be relative to the file's directory`},
+	}
+
+	for _, tt := range tests {
+
+		cfg.Hash = tt.hash
+		cfg.FilenamePattern = tt.pattern
+
+		_, _, err = NewPipeline(cfg).Build()
+		NotEqual(t, err, nil)
+		Equal(t, err.Error(), tt.err)
+	}
+}
@@ -0,0 +1,59 @@
+package assets
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// includeCache holds the includes resolved by the Development css_tag and js_tag funcs, so that
+// the files aren't read and lexed on every render; an entry is valid until the modification time
+// or size of any of the files it was resolved from changes.
+type includeCache struct {
+	mu      sync.RWMutex
+	entries map[string]*includeEntry // keyed by the file's logical name
+}
+
+type includeEntry struct {
+	files  []string             // the includes in the order they are required
+	states map[string]fileState // the file and all it's includes when resolved
+}
+
+// cachedIncludes returns the files included by name, recursively, in the order they are required,
+// resolving them again only if one of the files changed since they were cached.
+func (p *Pipeline) cachedIncludes(includes *includeCache, name string) ([]string, error) {
+
+	includes.mu.RLock()
+	entry, ok := includes.entries[name]
+	includes.mu.RUnlock()
+
+	if ok && p.unchanged(entry.states) {
+		return entry.files, nil
+	}
+
+	states := map[string]fileState{}
+
+	files, err := p.resolveIncludes(name, nil, states)
+	if err != nil {
+		return nil, err
+	}
+
+	includes.mu.Lock()
+	includes.entries[name] = &includeEntry{files: files, states: states}
+	includes.mu.Unlock()
+
+	return files, nil
+}
+
+// unchanged returns true if the modification time and size of every file of states is the same.
+func (p *Pipeline) unchanged(states map[string]fileState) bool {
+
+	for name, state := range states {
+
+		fi, err := fs.Stat(p.src, name)
+		if err != nil || !fi.ModTime().Equal(state.modTime) || fi.Size() != state.size {
+			return false
+		}
+	}
+
+	return true
+}
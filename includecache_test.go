@@ -0,0 +1,91 @@
+package assets
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+// readCountFS counts the files read, stats aren't counted.
+type readCountFS struct {
+	fstest.MapFS
+	reads *int32
+}
+
+func (r readCountFS) ReadFile(name string) ([]byte, error) {
+	atomic.AddInt32(r.reads, 1)
+	return r.MapFS.ReadFile(name)
+}
+
+func TestIncludeCache(t *testing.T) {
+
+	reads := new(int32)
+
+	in := fstest.MapFS{
+		"static/js/app.js":  {Data: []byte("//include(js/lib.js)\nvar app = 1;\n")},
+		"static/js/lib.js":  {Data: []byte("//include(js/util.js)\nvar lib = 1;\n")},
+		"static/js/util.js": {Data: []byte("var util = 1;\n")},
+		"static/js/new.js":  {Data: []byte("var n = 1;\n")},
+	}
+
+	cfg := Config{
+		InputDir:      "static",
+		Input:         struct{ fs.ReadFileFS }{readCountFS{MapFS: in, reads: reads}}, // hides MapFS's Sub and Stat
+		RelativeToDir: true,
+		Extensions:    map[string]struct{}{".js": {}},
+	}
+
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	tags := `<script type="text/javascript" src="/static/js/util.js"></script><script type="text/javascript" src="/static/js/lib.js"></script>` +
+		`<script type="text/javascript" src="/static/js/app.js"></script>`
+
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), tags)
+	Equal(t, atomic.LoadInt32(reads), int32(3))
+
+	// concurrent renders use the cached includes
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), tags)
+		}()
+	}
+
+	wg.Wait()
+	Equal(t, atomic.LoadInt32(reads), int32(3))
+
+	// a change to any of the files resolves the includes again
+	in["static/js/lib.js"] = &fstest.MapFile{Data: []byte("//include(js/new.js)\nvar lib = 1;\n")}
+
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="/static/js/new.js"></script>`+
+		`<script type="text/javascript" src="/static/js/lib.js"></script><script type="text/javascript" src="/static/js/app.js"></script>`)
+	Equal(t, atomic.LoadInt32(reads), int32(6))
+
+	in["static/js/new.js"] = &fstest.MapFile{Data: []byte("var n = 1;\n"), ModTime: time.Now()}
+
+	renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`)
+	Equal(t, atomic.LoadInt32(reads), int32(9))
+
+	// test BAD input
+	delete(in, "static/js/new.js")
+
+	_, err = NewPipeline(cfg).cachedIncludes(&includeCache{entries: map[string]*includeEntry{}}, "js/app.js")
+	NotEqual(t, err, nil)
+
+	tpl, err := template.New("test").Funcs(funcs).Parse(`{{ js_tag "js/app.js" }}`)
+	Equal(t, err, nil)
+
+	err = tpl.Execute(new(bytes.Buffer), nil)
+	NotEqual(t, err, nil)
+}
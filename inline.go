@@ -0,0 +1,113 @@
+package assets
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	styleTag        = htmlTag{name: "style"}
+	inlineScriptTag = htmlTag{name: "script"}
+
+	closingTagRegex = regexp.MustCompile(`(?i)</(script|style)`)
+)
+
+// inlineCache holds the contents of the hashed files inlined by the Production
+// css_inline and js_inline funcs, read once from the output.
+type inlineCache struct {
+	mu       sync.RWMutex
+	contents map[string]string // keyed by the hashed file
+}
+
+// createProdInlineFunc returns the css_inline or js_inline func, rendering tag containing the
+// contents of the hashed file; fallback is rendered pointing at the unhashed file with MissingFallback.
+func (p *Pipeline) createProdInlineFunc(manifest *Manifest, cache *inlineCache, tag htmlTag, fallback htmlTag) interface{} {
+	return func(name string, args ...string) (template.HTML, error) {
+
+		a := manifest.Asset(name)
+		if a == nil {
+			return p.prodTag(manifest, name, fallback, args)
+		}
+
+		attrs, err := parseTagAttrs(args)
+		if err != nil {
+			return "", err
+		}
+
+		content, err := p.inlineContent(cache, a)
+		if err != nil {
+			return "", err
+		}
+
+		return tag.renderInline(content, attrs), nil
+	}
+}
+
+// inlineContent returns the contents of the hashed file of a ready to be inlined; the
+// sourceMappingURL is made absolute as it would otherwise be relative to the page.
+func (p *Pipeline) inlineContent(cache *inlineCache, a *Asset) (string, error) {
+
+	cache.mu.RLock()
+	content, ok := cache.contents[a.File]
+	cache.mu.RUnlock()
+
+	if ok {
+		return content, nil
+	}
+
+	b, err := fs.ReadFile(p.out, a.File)
+	if err != nil {
+		return "", err
+	}
+
+	if a.SourceMapURL != "" {
+
+		extension := path.Ext(a.Name)
+		comment := []byte(sourceMapComment(extension, path.Base(a.SourceMap)))
+
+		if bytes.HasSuffix(b, comment) {
+			b = append(b[:len(b)-len(comment)], sourceMapComment(extension, a.SourceMapURL)...)
+		}
+	}
+
+	content = inlineSafe(string(b))
+
+	cache.mu.Lock()
+	cache.contents[a.File] = content
+	cache.mu.Unlock()
+
+	return content, nil
+}
+
+// createDevInlineFunc returns the css_inline or js_inline func, rendering tag containing the
+// file name freshly bundled from the source files, with the css references pointing at the source files.
+func (p *Pipeline) createDevInlineFunc(tag htmlTag) interface{} {
+	return func(name string, args ...string) (template.HTML, error) {
+
+		attrs, err := parseTagAttrs(args)
+		if err != nil {
+			return "", err
+		}
+
+		buff := new(bytes.Buffer)
+
+		if err = p.bundle(buff, name, &bundleState{comments: true, rewrite: true, dev: true}); err != nil {
+			return "", err
+		}
+
+		return tag.renderInline(inlineSafe(buff.String()), attrs), nil
+	}
+}
+
+// inlineSafe escapes the closing script and style tags within the js or css content,
+// "<\/" being equivalent within the strings and comments it can legitimately appear in.
+func inlineSafe(content string) string {
+	return closingTagRegex.ReplaceAllStringFunc(content, func(s string) string {
+		return strings.Replace(s, "</", `<\/`, 1)
+	})
+}
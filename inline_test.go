@@ -0,0 +1,103 @@
+package assets
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestInlineFuncs(t *testing.T) {
+
+	out := mapWriteFS{MapFS: fstest.MapFS{}}
+
+	in := fstest.MapFS{
+		"static/js/app.js":    {Data: []byte("//include(js/lib.js)\nvar app = \"</script>\";\n")},
+		"static/js/lib.js":    {Data: []byte("var lib = 1;\n")},
+		"static/css/site.css": {Data: []byte("body {\n  color: red;\n}\n")},
+		"static/css/page.css": {Data: []byte("body { background: url('../img/bg.png?v=1'); }\n")},
+		"static/img/bg.png":   {Data: []byte("PNG")},
+	}
+
+	cfg := Config{
+		InputDir:      "static",
+		Input:         in,
+		Output:        out,
+		RelativeToDir: true,
+		Extensions:    map[string]struct{}{".js": {}, ".css": {}},
+		Mode:          Production,
+	}
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	Equal(t, renderTemplate(t, funcs, `{{ css_inline "css/site.css" "nonce=abc" }}`), `<style nonce="abc">body{color:red}</style>`)
+
+	js := renderTemplate(t, funcs, `{{ js_inline "js/app.js" }}`)
+	MatchRegex(t, js, `^<script>var lib=1.*var app="<\\/script>".*</script>$`)
+
+	// the contents are read once
+	file := manifest.Asset("css/site.css").File
+	out.MapFS[file] = &fstest.MapFile{Data: []byte("changed")}
+
+	Equal(t, renderTemplate(t, funcs, `{{ css_inline "css/site.css" }}`), `<style>body{color:red}</style>`)
+
+	// the sourceMappingURL is made absolute
+	cfg.SourceMaps = true
+
+	_, _, err = NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err = NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	js = renderTemplate(t, funcs, `{{ js_inline "js/app.js" }}`)
+	Equal(t, strings.HasSuffix(js, "\n//# sourceMappingURL="+manifest.Asset("js/app.js").SourceMapURL+"\n</script>"), true)
+
+	// missing names follow the Missing policy
+	cfg.Missing = MissingFallback
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_inline "js/missing.js" "defer" }}`), `<script type="text/javascript" src="/static/js/missing.js" defer></script>`)
+
+	// development bundles the source files
+	cfg.Mode = Development
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	js = renderTemplate(t, funcs, `{{ js_inline "js/app.js" "type=module" }}`)
+	Equal(t, strings.HasPrefix(js, `<script type="module">`), true)
+	Equal(t, strings.Contains(js, "var lib = 1;\n"), true)
+	Equal(t, strings.Contains(js, `var app = "<\/script>";`), true)
+
+	// the css references are relative to the page rather than the css file
+	Equal(t, renderTemplate(t, funcs, `{{ css_inline "css/page.css" }}`), "<style>body { background: url('/static/img/bg.png?v=1'); }\n</style>")
+
+	// test BAD input
+	for _, text := range []string{`{{ css_inline "css/missing.css" }}`, `{{ css_inline "css/site.css" "onload=x" }}`} {
+
+		tpl, err := template.New("test").Funcs(funcs).Parse(text)
+		Equal(t, err, nil)
+
+		err = tpl.Execute(new(bytes.Buffer), nil)
+		NotEqual(t, err, nil)
+	}
+}
+
+func TestInlineSafe(t *testing.T) {
+	Equal(t, inlineSafe(`a</script>b</STYLE>c</div>`), `a<\/script>b<\/STYLE>c</div>`)
+}
@@ -0,0 +1,36 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+)
+
+const defaultIntegrity = "sha384"
+
+var integrityHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// validIntegrity returns an error if alg is not a Subresource Integrity hash algorithm.
+func validIntegrity(alg string) error {
+
+	if _, ok := integrityHashes[alg]; !ok {
+		return fmt.Errorf("invalid integrity hash algorithm %q, must be one of sha256, sha384 or sha512", alg)
+	}
+
+	return nil
+}
+
+// integrity returns the Subresource Integrity value, i.e. "sha384-<base64 digest>", of b.
+func integrity(alg string, b []byte) string {
+
+	h := integrityHashes[alg]()
+	h.Write(b)
+
+	return alg + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
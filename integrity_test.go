@@ -0,0 +1,66 @@
+package assets
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"io/fs"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestIntegrity(t *testing.T) {
+
+	cfg, out := testConfig()
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("js/app.js")
+
+	b, err := fs.ReadFile(out, a.File)
+	Equal(t, err, nil)
+
+	sum := sha512.Sum384(b)
+	Equal(t, a.Integrity, "sha384-"+base64.StdEncoding.EncodeToString(sum[:]))
+
+	cfg.Mode = Production
+
+	// not emitted unless enabled
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="`+a.URL+`"></script>`)
+
+	cfg.SRI = true
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="`+a.URL+`" integrity="`+a.Integrity+`" crossorigin="anonymous"></script>`)
+
+	css := manifest.Asset("css/site.css")
+	cfg.CrossOrigin = "use-credentials"
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ css_tag "css/site.css" }}`), `<link type="text/css" rel="stylesheet" href="`+css.URL+`" integrity="`+css.Integrity+`" crossorigin="use-credentials">`)
+
+	// sha256
+	cfg.Integrity = "sha256"
+
+	_, _, err = NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err = NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	MatchRegex(t, manifest.Asset("js/app.js").Integrity, "^sha256-")
+
+	// test BAD input
+	cfg.Integrity = "md5"
+
+	_, _, err = NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), `invalid integrity hash algorithm "md5", must be one of sha256, sha384 or sha512`)
+}
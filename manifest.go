@@ -0,0 +1,227 @@
+package assets
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	manifestVersion    = 1
+	legacyManifestFile = "manifest.txt"
+)
+
+// Manifest is the JSON manifest written by Build, it describes every processed asset.
+type Manifest struct {
+	Version int               `json:"version"`
+	Hash    string            `json:"hash,omitempty"` // hash algorithm of the asset hashes
+	Assets  map[string]*Asset `json:"assets"`         // keyed by the asset's logical name
+}
+
+// Asset contains the information of a single processed asset.
+type Asset struct {
+	Name         string              `json:"name"`                     // logical name, relative to the InputDir
+	URL          string              `json:"url"`                      // URL of the hashed file
+	File         string              `json:"file"`                     // hashed file within the output
+	Hash         string              `json:"hash"`                     // hex encoded hash of the bundled contents
+	Size         int64               `json:"size"`                     // size in bytes of the bundled contents
+	MinifiedSize int64               `json:"minified_size"`            // size in bytes of the hashed file
+	MIMEType     string              `json:"mime_type"`                // MIME type determined by the file extension
+	Includes     []string            `json:"includes,omitempty"`       // logical names of the files bundled into the asset
+	References   []string            `json:"references,omitempty"`     // logical names of the files referenced by css url() and @import
+	Integrity    string              `json:"integrity,omitempty"`      // Subresource Integrity of the hashed file
+	Variants     map[string]*Variant `json:"variants,omitempty"`       // pre-compressed variants keyed by Content-Encoding
+	SourceMap    string              `json:"source_map,omitempty"`     // source map of the hashed file
+	SourceMapURL string              `json:"source_map_url,omitempty"` // URL of the source map, blank when kept out of the output
+}
+
+// files returns the files of the asset within the output.
+func (a *Asset) files() []string {
+
+	files := []string{a.File}
+
+	for _, v := range a.Variants {
+		files = append(files, v.File)
+	}
+
+	if a.SourceMapURL != "" {
+		files = append(files, a.SourceMap)
+	}
+
+	return files
+}
+
+// Asset returns the asset for the provided logical name or nil if not present.
+func (m *Manifest) Asset(name string) *Asset {
+	return m.Assets[name]
+}
+
+// Names returns the sorted logical names of all assets.
+func (m *Manifest) Names() []string {
+
+	names := make([]string, 0, len(m.Assets))
+
+	for name := range m.Assets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// URL returns the URL of the asset with the provided logical name or
+// blank if not present.
+func (m *Manifest) URL(name string) string {
+
+	if a := m.Assets[name]; a != nil {
+		return a.URL
+	}
+
+	return ""
+}
+
+// Manifest reads the manifest created by Build from the output.
+func (p *Pipeline) Manifest() (*Manifest, error) {
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return p.loadManifest(p.out)
+}
+
+// loadManifest opens the JSON manifest, falling back to the legacy
+// text manifest written by older versions.
+func (p *Pipeline) loadManifest(fsys fs.FS) (*Manifest, error) {
+
+	f, err := fsys.Open(p.manifestName())
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = fsys.Open(path.Join(p.prefix, legacyManifestFile))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return p.readManifest(f)
+}
+
+// readManifest parses either manifest format.
+func (p *Pipeline) readManifest(r io.Reader) (*Manifest, error) {
+
+	br := bufio.NewReader(r)
+
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return &Manifest{Version: manifestVersion, Assets: map[string]*Asset{}}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+			continue
+		case '{':
+			return readJSONManifest(br)
+		}
+
+		return p.readLegacyManifest(br)
+	}
+}
+
+func readJSONManifest(r io.Reader) (*Manifest, error) {
+
+	manifest := new(Manifest)
+
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Version > manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	if manifest.Assets == nil {
+		manifest.Assets = map[string]*Asset{}
+	}
+
+	return manifest, nil
+}
+
+// readLegacyManifest parses the "old --> new" line format, the logical name being
+// the original filename relative to it's input dir, see legacyName.
+func (p *Pipeline) readLegacyManifest(r io.Reader) (*Manifest, error) {
+
+	manifest := &Manifest{Assets: map[string]*Asset{}}
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+
+		files := strings.SplitN(scanner.Text(), oldNewSeparator, 2)
+		if len(files) != 2 {
+			continue
+		}
+
+		name := legacyName(p.cfg.InputDir, files[0])
+		file := filepath.ToSlash(files[1])
+
+		manifest.Assets[name] = &Asset{Name: name, URL: "/" + file, File: file}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// legacyName returns the logical name of the original file of a legacy manifest found in dir. The
+// legacy manifest was written within the input dir, itself within the output dir when one was used,
+// so the input dir is the longest trailing part of dir the original file starts with.
+func legacyName(dir string, file string) string {
+
+	dir = strings.TrimLeft(path.Clean(filepath.ToSlash(dir)), "/")
+	file = strings.TrimLeft(filepath.ToSlash(file), "/")
+
+	for {
+
+		if strings.HasPrefix(file, dir+"/") {
+			return file[len(dir)+1:]
+		}
+
+		i := strings.IndexByte(dir, '/')
+		if i == -1 {
+			return file
+		}
+
+		dir = dir[i+1:]
+	}
+}
+
+func (p *Pipeline) writeManifest(out WriteFS, assets []*Asset) error {
+
+	manifest := &Manifest{Version: manifestVersion, Hash: p.cfg.Hash, Assets: make(map[string]*Asset, len(assets))}
+
+	for _, a := range assets {
+		manifest.Assets[a.Name] = a
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return out.WriteFile(p.manifestName(), append(b, '\n'))
+}
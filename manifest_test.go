@@ -0,0 +1,98 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestManifest(t *testing.T) {
+
+	cfg, out := testConfig()
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	Equal(t, manifest.Version, 1)
+	Equal(t, manifest.Names(), []string{"css/site.css", "images/logo.png", "js/app.js", "js/lib.js", "js/util.js"})
+
+	a := manifest.Asset("js/app.js")
+	NotEqual(t, a, nil)
+	Equal(t, a.Name, "js/app.js")
+	Equal(t, a.File, "static/js/app-"+a.Hash+".js")
+	Equal(t, a.URL, "/"+a.File)
+	Equal(t, len(a.Hash), 32)
+	Equal(t, a.Size, int64(len("var util = 1;\n\nvar lib = 1;\n\nvar app = 1;\n")))
+	Equal(t, a.MinifiedSize, int64(len("var util=1;var lib=1;var app=1;")))
+	MatchRegex(t, a.MIMEType, "javascript")
+	Equal(t, a.Includes, []string{"js/lib.js", "js/util.js"})
+
+	Equal(t, manifest.Asset("css/site.css").Includes, []string(nil))
+
+	// copied files are fingerprinted too
+	a = manifest.Asset("images/logo.png")
+	NotEqual(t, a, nil)
+	Equal(t, a.File, "static/images/logo-"+a.Hash+".png")
+	Equal(t, a.URL, "/"+a.File)
+	Equal(t, a.Size, int64(3))
+	Equal(t, a.MIMEType, "image/png")
+	Equal(t, a.Integrity, integrity(defaultIntegrity, []byte("PNG")))
+	Equal(t, string(out.MapFS[a.File].Data), "PNG")
+	Equal(t, string(out.MapFS["static/images/logo.png"].Data), "PNG")
+	Equal(t, manifest.Asset("missing.js"), (*Asset)(nil))
+	Equal(t, manifest.URL("missing.js"), "")
+
+	// test BAD input
+	out.MapFS["static/manifest.json"] = &fstest.MapFile{Data: []byte(`{"version": 2}`)}
+
+	_, err = NewPipeline(cfg).Manifest()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "unsupported manifest version 2")
+}
+
+func TestLegacyManifest(t *testing.T) {
+
+	legacy := "testfiles/test1/file1.txt --> testfiles/test1/file1-d41d8cd98f00b204e9800998ecf8427e.txt\n" +
+		"testfiles/test1/inner/file2.txt --> testfiles/test1/inner/file2-d41d8cd98f00b204e9800998ecf8427e.txt\n"
+
+	funcs, err := ProcessManifestFiles(strings.NewReader(legacy), "testfiles/test1", Production, false, "include(", ")")
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ css_tag "inner/file2.txt" }}`), `<link type="text/css" rel="stylesheet" href="/testfiles/test1/inner/file2-d41d8cd98f00b204e9800998ecf8427e.txt">`)
+
+	// legacy manifests are still found by the Pipeline
+	cfg := Config{
+		InputDir: "testfiles/test1",
+		Output:   fstest.MapFS{"testfiles/test1/manifest.txt": {Data: []byte(legacy)}},
+		Mode:     Production,
+	}
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	Equal(t, manifest.URL("file1.txt"), "/testfiles/test1/file1-d41d8cd98f00b204e9800998ecf8427e.txt")
+
+	funcs, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "file1.txt" }}`), `<script type="text/javascript" src="/testfiles/test1/file1-d41d8cd98f00b204e9800998ecf8427e.txt"></script>`)
+
+	// legacy manifests written within an output dir, i.e. -o public
+	dir := filepath.Join(t.TempDir(), "public", "static")
+
+	err = os.MkdirAll(dir, 0755)
+	Equal(t, err, nil)
+
+	err = os.WriteFile(filepath.Join(dir, "manifest.txt"), []byte("static/js/app.js --> static/js/app-abc.js\n"), 0644)
+	Equal(t, err, nil)
+
+	funcs, err = LoadManifestFiles(dir, Production, false, "include(", ")")
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, funcs, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="/static/js/app-abc.js"></script>`)
+
+	Equal(t, legacyName("public/web/static", "web/static/css/site.css"), "css/site.css")
+	Equal(t, legacyName("static", "static/static/app.js"), "static/app.js")
+}
@@ -0,0 +1,349 @@
+package assets
+
+import (
+	"html/template"
+	"io/fs"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-playground/bundler"
+)
+
+// Config contains the asset pipeline settings, it is shared between the build step
+// and the web application so that both are configured from a single value.
+type Config struct {
+
+	// InputDir is the asset directory to bundle files from recursively.
+	InputDir string
+
+	// OutputDir is the directory the processed files and manifest are written to;
+	// the InputDir path is recreated within it. If blank the current directory is used.
+	OutputDir string
+
+	// Input is the filesystem the assets are read from, InputDir being a directory within it,
+	// i.e. an embed.FS of the source assets. If nil InputDir is read from disk.
+	Input fs.FS
+
+	// Output is the filesystem the processed files and manifest are written to and read back
+	// from at runtime, it takes the place of OutputDir. It must implement WriteFS for Build,
+	// at runtime any fs.FS will do i.e. an embed.FS of the Build output.
+	// If nil OutputDir on disk is used.
+	Output fs.FS
+
+	// RelativeToDir specifies if the files included should be treated as relative to the InputDir,
+	// or relative to the files from which they are included.
+	RelativeToDir bool
+
+	// LeftDelim and RightDelim are the delimiters for file includes.
+	LeftDelim  string
+	RightDelim string
+
+	// Extensions are the file extensions to be bundled and minified, all other files
+	// are copied to the OutputDir as is.
+	Extensions map[string]struct{}
+
+	// Mode determines which template.FuncMap functions are created by FuncMap.
+	Mode RunMode
+
+	// DevBundle makes the Development css_tag and js_tag funcs emit a single tag per file,
+	// pointing at the bundle served by the DevHandler, instead of one tag per included file.
+	DevBundle bool
+
+	// Hash is the hash algorithm of the file contents used in the hashed filenames,
+	// "md5", "sha1", "sha256" or "fnv" (64-bit FNV-1a). If blank "md5" is used.
+	Hash string
+
+	// FilenamePattern is the name of the hashed files within the directory of the original file;
+	// "[name]" is replaced by the filename without extension, "[ext]" by the extension without dot,
+	// "[hash]" by the hash and "[hash:N]" by its first N characters, i.e. "[name].[hash:8].[ext]"
+	// or "[hash]/[name].[ext]". If blank "[name]-[hash].[ext]" is used.
+	FilenamePattern string
+
+	// Compressors create pre-compressed variants, i.e. Gzip(gzip.BestCompression), of every
+	// processed file; variants that aren't smaller than the original are skipped.
+	Compressors []Compressor
+
+	// Workers is the number of files processed concurrently by Build,
+	// if 0 the number of CPUs is used.
+	Workers int
+
+	// NoCache disables the build cache, written next to the manifest, that lets Build leave
+	// the output of files whose sources and options haven't changed untouched.
+	NoCache bool
+
+	// Integrity is the hash algorithm, "sha256", "sha384" or "sha512", of the Subresource Integrity
+	// digest recorded in the manifest for every processed file. If blank "sha384" is used.
+	Integrity string
+
+	// SRI makes the Production css_tag and js_tag funcs emit the integrity attribute, along
+	// with the crossorigin attribute set to CrossOrigin; "anonymous" if blank.
+	SRI         bool
+	CrossOrigin string
+
+	// BaseURL is the URL the Production files are served from, i.e. "https://cdn.example.com/static/",
+	// followed by the file within the output, without the StripPrefix, it makes up the URLs of the
+	// manifest. If blank "/" is used.
+	BaseURL string
+
+	// DevBaseURL is the URL the source files are served from in Development mode, i.e. a local server
+	// running the DevHandler "http://localhost:3000/". If blank "/" is used.
+	DevBaseURL string
+
+	// StripPrefix is removed from the paths of the files, within the output or the input in Development
+	// mode, to create their URLs; i.e. with InputDir "static" and StripPrefix "static" the hashed file
+	// "static/js/app-<hash>.js" has the URL "<BaseURL>js/app-<hash>.js".
+	StripPrefix string
+
+	// Missing determines how the Production template funcs handle names missing from the manifest.
+	Missing MissingPolicy
+
+	// ErrorLog logs the names missing from the manifest with MissingComment,
+	// if nil the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	// SourceMaps writes a version 3 source map, the hashed filename followed by ".map", for every
+	// minified js and css file, mapping it back to the original files, and appends the
+	// sourceMappingURL comment to the hashed file.
+	SourceMaps bool
+
+	// SourceMapOutput, when set, is the filesystem the source maps are written to instead of the Output,
+	// keeping them private i.e. for uploading to an error tracker; no sourceMappingURL comment is appended
+	// and previous source maps are never removed from it.
+	SourceMapOutput WriteFS
+
+	// FuncPrefix is prepended to the names of the template funcs, i.e. "admin_" for admin_css_tag,
+	// so that the FuncMaps of several Pipelines can be merged with MergeFuncMaps.
+	FuncPrefix string
+
+	// FuncNames renames the template funcs, keyed by their default name i.e. {"css_tag": "stylesheet"},
+	// FuncPrefix is prepended to the new names.
+	FuncNames map[string]string
+}
+
+// MissingPolicy determines how the Production template funcs handle names missing from the manifest.
+type MissingPolicy int
+
+// MissingPolicy's
+const (
+	MissingError    MissingPolicy = iota // return an error, failing the template execution
+	MissingComment                       // log and render an HTML comment in place of the tag, asset_url renders blank
+	MissingFallback                      // use the URL of the unhashed file
+)
+
+// Pipeline is the asset pipeline created from a Config.
+type Pipeline struct {
+	cfg Config
+
+	src     fs.FS  // the InputDir
+	out     fs.FS  // the OutputDir
+	prefix  string // the InputDir path recreated within the output and used for URLs
+	overlap string // the output within the InputDir, see outputOverlap
+	err     error  // invalid Config reported by Build and FuncMap
+}
+
+// NewPipeline returns a new Pipeline for the provided Config.
+func NewPipeline(cfg Config) *Pipeline {
+
+	cfg.InputDir = filepath.Clean(cfg.InputDir)
+	cfg.OutputDir = filepath.Clean(cfg.OutputDir)
+
+	p := &Pipeline{
+		cfg:    cfg,
+		src:    cfg.Input,
+		out:    cfg.Output,
+		prefix: outputPrefix(cfg.InputDir),
+	}
+
+	if p.src == nil {
+		p.src = dirFS(cfg.InputDir)
+	} else {
+		p.src, p.err = fs.Sub(p.src, filepath.ToSlash(cfg.InputDir))
+	}
+
+	if p.out == nil {
+		p.out = dirFS(cfg.OutputDir)
+	}
+
+	p.overlap = outputOverlap(p.cfg, p.prefix)
+
+	if p.cfg.Hash == "" {
+		p.cfg.Hash = defaultHash
+	}
+
+	if p.cfg.FilenamePattern == "" {
+		p.cfg.FilenamePattern = defaultFilenamePattern
+	}
+
+	if p.cfg.Integrity == "" {
+		p.cfg.Integrity = defaultIntegrity
+	}
+
+	if p.cfg.CrossOrigin == "" {
+		p.cfg.CrossOrigin = "anonymous"
+	}
+
+	p.cfg.BaseURL = baseURL(p.cfg.BaseURL)
+	p.cfg.DevBaseURL = baseURL(p.cfg.DevBaseURL)
+
+	if p.cfg.StripPrefix = strings.Trim(path.Clean("/"+filepath.ToSlash(p.cfg.StripPrefix)), "/"); p.cfg.StripPrefix != "" {
+		p.cfg.StripPrefix += "/"
+	}
+
+	if p.err == nil {
+		p.err = p.validate()
+	}
+
+	return p
+}
+
+func (p *Pipeline) validate() error {
+
+	if err := validHash(p.cfg.Hash); err != nil {
+		return err
+	}
+
+	if err := validFilenamePattern(p.cfg.FilenamePattern, p.cfg.Hash); err != nil {
+		return err
+	}
+
+	if err := validIntegrity(p.cfg.Integrity); err != nil {
+		return err
+	}
+
+	return p.validFuncNames()
+}
+
+// outputPrefix returns dir as a valid io/fs path, volume names and leading
+// separators or parent references are dropped.
+func outputPrefix(dir string) string {
+
+	dir = filepath.ToSlash(strings.TrimPrefix(dir, filepath.VolumeName(dir)))
+	dir = strings.TrimLeft(dir, "/")
+
+	for strings.HasPrefix(dir, "../") {
+		dir = dir[3:]
+	}
+
+	if dir == "" || dir == ".." {
+		return "."
+	}
+
+	return dir
+}
+
+// outputOverlap returns the directory within the InputDir the output of Build is written to, i.e. with
+// the OutputDir within the InputDir, or "." when the output is written next to the source files, i.e. with
+// the OutputDir being the parent of the InputDir; blank when the output isn't within the InputDir.
+func outputOverlap(cfg Config, prefix string) string {
+
+	if cfg.Input != nil || cfg.Output != nil {
+		return ""
+	}
+
+	in, err := filepath.Abs(cfg.InputDir)
+	if err != nil {
+		return ""
+	}
+
+	out, err := filepath.Abs(cfg.OutputDir)
+	if err != nil {
+		return ""
+	}
+
+	root := relDir(in, filepath.Join(out, filepath.FromSlash(prefix)))
+
+	if root == "" || root == "." {
+		return root
+	}
+
+	// everything within the OutputDir is skipped, not only this Pipeline's output
+	if dir := relDir(in, out); dir != "" && dir != "." {
+		return dir
+	}
+
+	return root
+}
+
+// relDir returns the slash separated path of target within base, blank if it isn't within base.
+func relDir(base string, target string) string {
+
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return ""
+	}
+
+	if rel = filepath.ToSlash(rel); rel == ".." || strings.HasPrefix(rel, "../") {
+		return ""
+	}
+
+	return rel
+}
+
+// baseURL returns u ending with a slash, "/" when blank.
+func baseURL(u string) string {
+
+	if !strings.HasSuffix(u, "/") {
+		u += "/"
+	}
+
+	return u
+}
+
+// publicURL returns the URL of file, a path within the output or the input in Development mode,
+// base being the base URL of the mode.
+func (p *Pipeline) publicURL(base string, file string) string {
+	return base + strings.TrimPrefix(file, p.cfg.StripPrefix)
+}
+
+// Config returns a copy of the Pipeline's Config.
+func (p *Pipeline) Config() Config {
+	return p.cfg
+}
+
+// ManifestPath returns the location of the manifest file written by Build,
+// when a Config.Output is used it's the name within that filesystem.
+func (p *Pipeline) ManifestPath() string {
+
+	if p.cfg.Output != nil {
+		return p.manifestName()
+	}
+
+	return filepath.Join(p.cfg.OutputDir, filepath.FromSlash(p.manifestName()))
+}
+
+func (p *Pipeline) manifestName() string {
+	return path.Join(p.prefix, manifestFile)
+}
+
+// Build processes (bundles, compresses...) the assets for use and creates the Manifest file,
+// it returns the processed files and the location of the manifest.
+func (p *Pipeline) Build() ([]*bundler.ProcessedFile, string, error) {
+
+	if p.err != nil {
+		return nil, "", p.err
+	}
+
+	return p.generate()
+}
+
+// FuncMap returns the template.FuncMap for the Pipeline's RunMode; in Production mode
+// it reads the manifest file created by Build, or the legacy manifest.txt of older versions.
+func (p *Pipeline) FuncMap() (template.FuncMap, error) {
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if p.cfg.Mode != Production {
+		return p.loadMapFuncs(nil), nil
+	}
+
+	manifest, err := p.loadManifest(p.out)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.loadMapFuncs(manifest), nil
+}
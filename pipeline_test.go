@@ -0,0 +1,105 @@
+package assets
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestPipeline(t *testing.T) {
+
+	cfg := Config{
+		InputDir:   "testfiles/test1",
+		OutputDir:  "testfiles/pipelineoutput",
+		LeftDelim:  "include(",
+		RightDelim: ")",
+		Extensions: extensions,
+	}
+
+	p := NewPipeline(cfg)
+	Equal(t, p.ManifestPath(), "testfiles/pipelineoutput/testfiles/test1/manifest.json")
+
+	processed, manifest, err := p.Build()
+	Equal(t, err, nil)
+	Equal(t, manifest, p.ManifestPath())
+	Equal(t, len(processed), 3)
+
+	cfg.Mode = Production
+
+	funcs, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, len(funcs), 5)
+
+	tpl, err := template.New("test").Funcs(funcs).Parse(`{{ js_tag "file1.txt" }}`)
+	Equal(t, err, nil)
+
+	buff := new(bytes.Buffer)
+	err = tpl.Execute(buff, nil)
+	Equal(t, err, nil)
+	MatchRegex(t, buff.String(), `^<script type="text/javascript" src="/testfiles/test1/file1-[0-9a-f]{32}\.txt"></script>$`)
+
+	err = os.RemoveAll("testfiles/pipelineoutput")
+	Equal(t, err, nil)
+
+	// test BAD input
+	cfg.OutputDir = "testfiles/pipelineoutputofnonexistant"
+
+	_, err = NewPipeline(cfg).FuncMap()
+	NotEqual(t, err, nil)
+}
+
+func TestBaseURL(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	cfg.BaseURL = "https://cdn.example.com/assets"
+	cfg.DevBaseURL = "http://localhost:3000/dev/"
+	cfg.StripPrefix = "/static"
+
+	p := NewPipeline(cfg)
+	Equal(t, p.Config().BaseURL, "https://cdn.example.com/assets/")
+	Equal(t, p.Config().StripPrefix, "static/")
+
+	_, _, err := p.Build()
+	Equal(t, err, nil)
+
+	manifest, err := p.Manifest()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("js/app.js")
+	Equal(t, a.URL, "https://cdn.example.com/assets/js/app-"+a.Hash+".js")
+	Equal(t, manifest.URL("images/logo.png"), "https://cdn.example.com/assets/images/logo-"+manifest.Asset("images/logo.png").Hash+".png")
+
+	dev, err := p.FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, dev, `{{ asset_url "images/logo.png" }}`), "http://localhost:3000/dev/images/logo.png")
+	Equal(t, renderTemplate(t, dev, `{{ css_tag "css/site.css" }}`), `<link type="text/css" rel="stylesheet" href="http://localhost:3000/dev/css/site.css">`)
+
+	cfg.Mode = Production
+
+	prod, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, prod, `{{ js_tag "js/app.js" }}`), `<script type="text/javascript" src="`+a.URL+`"></script>`)
+
+	// the handlers serve the paths of the URLs
+	serve := func(h http.Handler, target string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code
+	}
+
+	h, err := NewPipeline(cfg).Handler()
+	Equal(t, err, nil)
+	Equal(t, serve(h, "/assets/js/app-"+a.Hash+".js"), http.StatusOK)
+	Equal(t, serve(h, "/"+a.File), http.StatusNotFound)
+
+	dh := NewPipeline(cfg).DevHandler()
+	Equal(t, serve(dh, "/dev/js/app.js"), http.StatusOK)
+	Equal(t, serve(dh, "/dev/static/js/app.js"), http.StatusNotFound)
+	Equal(t, serve(dh, "/static/js/app.js"), http.StatusNotFound)
+}
@@ -0,0 +1,180 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/parse/css"
+)
+
+// piece is a part of the text of a file written to the bundle, pos being it's position within the file.
+type piece struct {
+	val string
+	pos int
+}
+
+// rewriteCSS splits the css text of the file name, starting at pos within the file b, into pieces with
+// every url() and @import reference replaced by the URL of the file it references, see referenceURL.
+func (p *Pipeline) rewriteCSS(name string, b []byte, text string, pos int, state *bundleState) ([]piece, error) {
+
+	var pieces []piece
+	var offset, last int
+	var imports bool
+
+	l := css.NewLexer(strings.NewReader(text))
+
+	for {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			break
+		}
+
+		var ref string
+		var start int
+
+		switch tt {
+		case css.URLToken:
+			ref, start = urlTokenValue(data)
+		case css.StringToken:
+			if imports {
+				ref, start = string(data[1:len(data)-1]), 1
+			}
+		}
+
+		if tt != css.WhitespaceToken && tt != css.CommentToken {
+			imports = tt == css.AtKeywordToken && strings.EqualFold(string(data), "@import")
+		}
+
+		if ref != "" {
+
+			url, err := p.referenceURL(name, ref, lineOf(b, pos+offset), state)
+			if err != nil {
+				return nil, err
+			}
+
+			if url != ref {
+				start += offset
+				pieces = append(pieces, piece{val: text[last:start], pos: pos + last}, piece{val: url, pos: pos + start})
+				last = start + len(ref)
+			}
+		}
+
+		offset += len(data)
+		l.Free(len(data))
+	}
+
+	return append(pieces, piece{val: text[last:], pos: pos + last}), nil
+}
+
+// urlTokenValue returns the reference of the url() token data and it's position within data.
+func urlTokenValue(data []byte) (string, int) {
+
+	start := bytes.IndexByte(data, '(') + 1
+	end := bytes.LastIndexByte(data, ')')
+
+	if end < start {
+		end = len(data)
+	}
+
+	for start < end && isCSSSpace(data[start]) {
+		start++
+	}
+
+	for end > start && isCSSSpace(data[end-1]) {
+		end--
+	}
+
+	if end-start >= 2 && (data[start] == '"' || data[start] == '\'') && data[end-1] == data[start] {
+		start++
+		end--
+	}
+
+	return string(data[start:end]), start
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// referenceURL returns the URL of the hashed file, or the source file in Development mode, referenced by
+// ref on the line of the file name; ref is returned as is when it doesn't reference a file within the
+// InputDir, i.e. absolute and data URLs or fragments.
+func (p *Pipeline) referenceURL(name string, ref string, line int, state *bundleState) (string, error) {
+
+	if ref == "" || ref[0] == '/' || ref[0] == '#' || strings.Contains(ref, ":") {
+		return ref, nil
+	}
+
+	file, suffix := ref, ""
+
+	if i := strings.IndexAny(ref, "?#"); i != -1 {
+		file, suffix = ref[:i], ref[i:]
+	}
+
+	file = path.Join(path.Dir(name), file)
+
+	if file == ".." || strings.HasPrefix(file, "../") {
+		return "", fmt.Errorf("%s:%d: %s references a file outside the InputDir", name, line, ref)
+	}
+
+	b, err := fs.ReadFile(p.src, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%s:%d: %s references missing file %s", name, line, ref, file)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// relative to the page the css is bundled into, or inlined within, rather than the css file
+	if state.dev {
+		return p.devURL(file) + suffix, nil
+	}
+
+	sources := map[string]string{file: sourceHash(b)}
+	hash := p.contentHash(b)
+
+	// the hashed name of a processed file depends on it's bundle, which is created the same way Build creates it
+	if p.isProcessed(file) {
+
+		for i, f := range state.stack {
+			if f == file {
+				return "", &CycleError{Cycle: append(append([]string(nil), state.stack[i:]...), file), File: name, Line: line}
+			}
+		}
+
+		refState := &bundleState{sources: sources, stack: append([]string(nil), state.stack...), rewrite: true}
+		buff := new(bytes.Buffer)
+
+		if err = p.bundle(buff, file, refState); err != nil {
+			return "", err
+		}
+
+		hash = p.contentHash(buff.Bytes())
+	}
+
+	files := make([]string, 0, len(sources))
+
+	for source := range sources {
+		files = append(files, source)
+	}
+
+	sort.Strings(files)
+
+	for _, source := range files {
+
+		if state.sources != nil {
+			state.sources[source] = sources[source]
+		}
+
+		if !contains(state.references, source) {
+			state.references = append(state.references, source)
+		}
+	}
+
+	return p.publicURL(p.cfg.BaseURL, p.hashedName(file, path.Ext(file), hash)) + suffix, nil
+}
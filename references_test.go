@@ -0,0 +1,115 @@
+package assets
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestCSSReferences(t *testing.T) {
+
+	out := mapWriteFS{MapFS: fstest.MapFS{}}
+
+	in := fstest.MapFS{
+		"static/css/site.css": {Data: []byte(`@import "reset.css";
+body { background: url(../images/bg.png) no-repeat; }
+@font-face { src: url("../fonts/icons.woff?v=1#icons"); }
+.a { background: url(data:image/png;base64,AAAA); }
+.b { filter: url(#blur); background: url(/absolute.png); }
+`)},
+		"static/css/reset.css":     {Data: []byte("html { background: url('../images/bg.png'); }\n")},
+		"static/images/bg.png":     {Data: []byte("PNG")},
+		"static/fonts/icons.woff":  {Data: []byte("WOFF")},
+		"static/images/LICENSE":    {Data: []byte("MIT")},
+		"static/images/unused.png": {Data: []byte("UNUSED")},
+	}
+
+	cfg := Config{
+		InputDir:   "static",
+		Input:      in,
+		Output:     out,
+		Extensions: map[string]struct{}{".css": {}},
+	}
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	p := NewPipeline(cfg)
+	bg := p.hashedName("images/bg.png", ".png", p.contentHash([]byte("PNG")))
+	icons := p.hashedName("fonts/icons.woff", ".woff", p.contentHash([]byte("WOFF")))
+
+	// every copied file is fingerprinted as well as copied as is
+	for _, file := range []string{bg, icons, "static/images/bg.png", "static/images/LICENSE", p.hashedName("images/LICENSE", "", p.contentHash([]byte("MIT")))} {
+		_, err = fs.Stat(out, file)
+		Equal(t, err, nil)
+	}
+
+	MatchRegex(t, bg, `^static/images/bg-[0-9a-f]{32}\.png$`)
+	MatchRegex(t, p.hashedName("images/LICENSE", "", "abc"), `^static/images/LICENSE-abc$`)
+
+	reset := manifest.Asset("css/reset.css")
+	Equal(t, reset.References, []string{"images/bg.png"})
+
+	b, err := fs.ReadFile(out, reset.File)
+	Equal(t, err, nil)
+	Equal(t, strings.Contains(string(b), `url(/`+bg+`)`), true)
+
+	site := manifest.Asset("css/site.css")
+	Equal(t, site.References, []string{"css/reset.css", "images/bg.png", "fonts/icons.woff"})
+
+	b, err = fs.ReadFile(out, site.File)
+	Equal(t, err, nil)
+
+	css := string(b)
+	Equal(t, strings.Contains(css, `@import "/`+reset.File+`"`) || strings.Contains(css, `@import url(/`+reset.File+`)`), true)
+	Equal(t, strings.Contains(css, `url(/`+bg+`)`), true)
+	Equal(t, strings.Contains(css, `/`+icons+`?v=1#icons`), true)
+	Equal(t, strings.Contains(css, `url(data:image/png`), true)
+	Equal(t, strings.Contains(css, `url(#blur)`), true)
+	Equal(t, strings.Contains(css, `url(/absolute.png)`), true)
+
+	// a changed reference rebuilds the css referencing it
+	in["static/images/bg.png"] = &fstest.MapFile{Data: []byte("PNG2")}
+
+	processed, err := NewPipeline(cfg).Update([]string{"images/bg.png"})
+	Equal(t, err, nil)
+	Equal(t, len(processed), 3)
+
+	manifest, err = NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	b, err = fs.ReadFile(out, manifest.Asset("css/site.css").File)
+	Equal(t, err, nil)
+	Equal(t, strings.Contains(string(b), `url(/`+p.hashedName("images/bg.png", ".png", p.contentHash([]byte("PNG2")))+`)`), true)
+
+	// test BAD input
+	in["static/css/site.css"] = &fstest.MapFile{Data: []byte("body {\n  background: url(../images/missing.png);\n}\n")}
+
+	_, _, err = NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "css/site.css:2: ../images/missing.png references missing file images/missing.png")
+
+	in["static/css/site.css"] = &fstest.MapFile{Data: []byte("body { background: url(../../bg.png); }\n")}
+
+	_, _, err = NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), "css/site.css:1: ../../bg.png references a file outside the InputDir")
+
+	in["static/css/site.css"] = &fstest.MapFile{Data: []byte("@import \"reset.css\";\n")}
+	in["static/css/reset.css"] = &fstest.MapFile{Data: []byte("\n@import url(site.css);\n")}
+
+	_, _, err = NewPipeline(cfg).Build()
+	NotEqual(t, err, nil)
+
+	cycle, ok := err.(*CycleError)
+	Equal(t, ok, true)
+	Equal(t, cycle.File, "css/site.css")
+	Equal(t, cycle.Line, 1)
+	Equal(t, err.Error(), "css/site.css:1: include cycle css/reset.css -> css/site.css -> css/reset.css")
+}
@@ -0,0 +1,326 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/tdewolff/parse/css"
+	"github.com/tdewolff/parse/js"
+)
+
+const (
+	sourceMapVersion   = 3
+	sourceMapExtension = ".map"
+
+	// alignWindow is the number of bundled tokens searched for a match of every minified token,
+	// the minifiers only drop or shorten tokens and never reorder them.
+	alignWindow = 64
+
+	base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+// sourceMap is a version 3 source map.
+type sourceMap struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file"`
+	SourceRoot     string   `json:"sourceRoot,omitempty"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+}
+
+// segment records that the bundled output starting at offset was copied from file, starting at pos.
+type segment struct {
+	offset int
+	file   string
+	pos    int
+}
+
+// token is a token of js or css, offset being it's position within the lexed contents.
+type token struct {
+	data   string
+	offset int
+}
+
+// mapping maps a position of the generated file to a position of a source file,
+// lines and columns are zero based.
+type mapping struct {
+	line, col       int
+	source          int
+	srcLine, srcCol int
+}
+
+// writeSourceMap creates the source map of the processed file a, writing it next to the hashed file,
+// or to the Config.SourceMapOutput, and returns the sourceMappingURL comment to append to the hashed
+// file; blank when the source map is kept out of the output.
+func (p *Pipeline) writeSourceMap(out WriteFS, a *Asset, bundled []byte, minified []byte, state *bundleState) (string, error) {
+
+	b, err := p.sourceMap(a.File, path.Ext(a.Name), bundled, minified, state)
+	if err != nil {
+		return "", err
+	}
+
+	a.SourceMap = a.File + sourceMapExtension
+
+	if p.cfg.SourceMapOutput != nil {
+		return "", p.cfg.SourceMapOutput.WriteFile(a.SourceMap, b)
+	}
+
+	if err = out.WriteFile(a.SourceMap, b); err != nil {
+		return "", err
+	}
+
+	a.SourceMapURL = a.URL + sourceMapExtension
+
+	return sourceMapComment(path.Ext(a.Name), path.Base(a.SourceMap)), nil
+}
+
+// sourceMapComment returns the comment, appended to the js or css file, referencing the source map at url.
+func sourceMapComment(extension string, url string) string {
+
+	if extension == ".css" {
+		return fmt.Sprintf("\n/*# sourceMappingURL=%s */\n", url)
+	}
+
+	return fmt.Sprintf("\n//# sourceMappingURL=%s\n", url)
+}
+
+// sourceMap returns the source map of the minified contents of the hashed file, bundled being the contents
+// before minification. The minified tokens are aligned with the bundled ones, which are mapped back through
+// the bundle's segments to the original files.
+func (p *Pipeline) sourceMap(file string, extension string, bundled []byte, minified []byte, state *bundleState) ([]byte, error) {
+
+	sm := &sourceMap{
+		Version:        sourceMapVersion,
+		File:           path.Base(file),
+		Sources:        []string{},
+		SourcesContent: []string{},
+		Names:          []string{},
+	}
+
+	// the sources are embedded, the root only names them after their path on the server; the
+	// DevBaseURL, i.e. a local server, mustn't end up in the maps of Production builds
+	if p.prefix != "." {
+		sm.SourceRoot = p.publicURL("/", p.prefix+"/")
+	}
+
+	sources := map[string]int{}
+	lines := map[string][]int{}
+
+	bundledTokens := lexTokens(bundled, extension)
+	gen := &cursor{b: minified}
+
+	var mappings []mapping
+	i := 0
+
+	for _, t := range lexTokens(minified, extension) {
+
+		for j := i; j < len(bundledTokens) && j < i+alignWindow; j++ {
+
+			if bundledTokens[j].data != t.data {
+				continue
+			}
+
+			i = j + 1
+
+			seg := findSegment(state.segments, bundledTokens[j].offset)
+			if seg == nil {
+				break
+			}
+
+			src, ok := sources[seg.file]
+			if !ok {
+				src = len(sm.Sources)
+				sources[seg.file] = src
+				lines[seg.file] = lineStarts(state.contents[seg.file])
+
+				sm.Sources = append(sm.Sources, seg.file)
+				sm.SourcesContent = append(sm.SourcesContent, string(state.contents[seg.file]))
+			}
+
+			m := mapping{source: src}
+			m.line, m.col = gen.advance(t.offset)
+			m.srcLine, m.srcCol = position(state.contents[seg.file], lines[seg.file], seg.pos+bundledTokens[j].offset-seg.offset)
+
+			mappings = append(mappings, m)
+
+			break
+		}
+	}
+
+	sm.Mappings = encodeMappings(mappings)
+
+	return json.Marshal(sm)
+}
+
+// lexTokens returns the tokens of the js or css b, whitespace and comments excluded.
+func lexTokens(b []byte, extension string) []token {
+
+	var tokens []token
+	var offset int
+
+	add := func(data []byte, skip bool) {
+		if !skip {
+			tokens = append(tokens, token{data: string(data), offset: offset})
+		}
+		offset += len(data)
+	}
+
+	if extension == ".css" {
+
+		l := css.NewLexer(bytes.NewReader(b))
+
+		for {
+			tt, data := l.Next()
+			if tt == css.ErrorToken {
+				return tokens
+			}
+
+			add(data, tt == css.WhitespaceToken || tt == css.CommentToken)
+			l.Free(len(data))
+		}
+	}
+
+	l := js.NewLexer(bytes.NewReader(b))
+
+	for {
+		tt, data := l.Next()
+		if tt == js.ErrorToken {
+			return tokens
+		}
+
+		add(data, tt == js.WhitespaceToken || tt == js.LineTerminatorToken || tt == js.CommentToken)
+		l.Free(len(data))
+	}
+}
+
+// findSegment returns the segment containing the bundled offset.
+func findSegment(segments []segment, offset int) *segment {
+
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].offset > offset })
+	if i == 0 {
+		return nil
+	}
+
+	return &segments[i-1]
+}
+
+// lineStarts returns the offsets of the start of every line of b.
+func lineStarts(b []byte) []int {
+
+	starts := []int{0}
+
+	for i, c := range b {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+
+	return starts
+}
+
+// position returns the line and column of offset within b, the column counted
+// in UTF-16 code units as source maps require.
+func position(b []byte, starts []int, offset int) (int, int) {
+
+	line := sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+
+	return line, utf16Len(b[starts[line]:offset])
+}
+
+// cursor tracks the line and column of increasing offsets within b.
+type cursor struct {
+	b         []byte
+	offset    int
+	line, col int
+}
+
+func (c *cursor) advance(offset int) (int, int) {
+
+	for c.offset < offset {
+
+		r, size := utf8.DecodeRune(c.b[c.offset:])
+
+		switch {
+		case r == '\n':
+			c.line++
+			c.col = 0
+		case r >= 0x10000:
+			c.col += 2
+		default:
+			c.col++
+		}
+
+		c.offset += size
+	}
+
+	return c.line, c.col
+}
+
+func utf16Len(b []byte) int {
+	c := &cursor{b: b}
+	_, col := c.advance(len(b))
+	return col
+}
+
+// encodeMappings returns the Base64 VLQ encoded mappings, ordered by generated position.
+func encodeMappings(mappings []mapping) string {
+
+	var buff bytes.Buffer
+	var line, col, source, srcLine, srcCol int
+	var separate bool
+
+	for _, m := range mappings {
+
+		// columns are relative to the previous mapping on the same line
+		for ; line < m.line; line++ {
+			buff.WriteByte(';')
+			col = 0
+			separate = false
+		}
+
+		if separate {
+			buff.WriteByte(',')
+		}
+
+		separate = true
+
+		writeVLQ(&buff, m.col-col)
+		writeVLQ(&buff, m.source-source)
+		writeVLQ(&buff, m.srcLine-srcLine)
+		writeVLQ(&buff, m.srcCol-srcCol)
+
+		col, source, srcLine, srcCol = m.col, m.source, m.srcLine, m.srcCol
+	}
+
+	return buff.String()
+}
+
+// writeVLQ writes the Base64 VLQ encoding of v.
+func writeVLQ(buff *bytes.Buffer, v int) {
+
+	u := v << 1
+	if v < 0 {
+		u = -v<<1 | 1
+	}
+
+	for {
+		digit := u & 31
+		u >>= 5
+
+		if u > 0 {
+			digit |= 32
+		}
+
+		buff.WriteByte(base64Digits[digit])
+
+		if u == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+// decodeMappings decodes the VLQ mappings into absolute positions, keyed by generated "line:col".
+func decodeMappings(t *testing.T, mappings string) map[[2]int][3]int {
+
+	decoded := map[[2]int][3]int{}
+	var source, srcLine, srcCol int
+
+	for line, group := range strings.Split(mappings, ";") {
+
+		var col int
+
+		if group == "" {
+			continue
+		}
+
+		for _, seg := range strings.Split(group, ",") {
+
+			var values []int
+			var v, shift int
+
+			for _, c := range []byte(seg) {
+
+				digit := strings.IndexByte(base64Digits, c)
+				v += (digit & 31) << shift
+
+				if digit&32 != 0 {
+					shift += 5
+					continue
+				}
+
+				if v&1 == 1 {
+					values = append(values, -(v >> 1))
+				} else {
+					values = append(values, v>>1)
+				}
+
+				v, shift = 0, 0
+			}
+
+			Equal(t, len(values), 4)
+
+			col += values[0]
+			source += values[1]
+			srcLine += values[2]
+			srcCol += values[3]
+
+			decoded[[2]int{line, col}] = [3]int{source, srcLine, srcCol}
+		}
+	}
+
+	return decoded
+}
+
+func TestSourceMaps(t *testing.T) {
+
+	cfg, out := testConfig()
+
+	cfg.SourceMaps = true
+	cfg.DevBaseURL = "http://localhost:3000/"
+
+	p := NewPipeline(cfg)
+
+	_, _, err := p.Build()
+	Equal(t, err, nil)
+
+	manifest, err := p.Manifest()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("js/app.js")
+	Equal(t, a.SourceMap, a.File+".map")
+	Equal(t, a.SourceMapURL, a.URL+".map")
+
+	b, err := fs.ReadFile(out, a.File)
+	Equal(t, err, nil)
+	Equal(t, strings.HasSuffix(string(b), "\n//# sourceMappingURL="+strings.TrimPrefix(a.SourceMap, "static/js/")+"\n"), true)
+	Equal(t, a.MinifiedSize, int64(len(b)))
+	Equal(t, a.Integrity, integrity(defaultIntegrity, b))
+
+	mb, err := fs.ReadFile(out, a.SourceMap)
+	Equal(t, err, nil)
+
+	sm := new(sourceMap)
+	Equal(t, json.Unmarshal(mb, sm), nil)
+	Equal(t, sm.Version, 3)
+	Equal(t, sm.File, strings.TrimPrefix(a.File, "static/js/"))
+	Equal(t, sm.SourceRoot, "/static/")
+	Equal(t, sm.Sources, []string{"js/util.js", "js/lib.js", "js/app.js"})
+	Equal(t, sm.SourcesContent[2], "//include(js/lib.js)\nvar app = 1;\n")
+
+	// every identifier maps back to it's declaration in the original file
+	decoded := decodeMappings(t, sm.Mappings)
+
+	for i, name := range []string{"util", "lib", "app"} {
+
+		col := bytes.Index(b, []byte(name))
+		NotEqual(t, col, -1)
+
+		pos, ok := decoded[[2]int{0, col}]
+		Equal(t, ok, true)
+		Equal(t, pos, [3]int{i, strings.Count(sm.SourcesContent[i], "\n") - 1, 4})
+	}
+
+	css := manifest.Asset("css/site.css")
+	Equal(t, css.SourceMap, css.File+".map")
+
+	b, err = fs.ReadFile(out, css.File)
+	Equal(t, err, nil)
+	Equal(t, strings.HasSuffix(string(b), "\n/*# sourceMappingURL="+strings.TrimPrefix(css.SourceMap, "static/css/")+" */\n"), true)
+
+	mb, err = fs.ReadFile(out, css.SourceMap)
+	Equal(t, err, nil)
+
+	sm = new(sourceMap)
+	Equal(t, json.Unmarshal(mb, sm), nil)
+	Equal(t, sm.Sources, []string{"css/site.css"})
+
+	decoded = decodeMappings(t, sm.Mappings)
+
+	pos, ok := decoded[[2]int{0, bytes.Index(b, []byte("color"))}]
+	Equal(t, ok, true)
+	Equal(t, pos, [3]int{0, 1, 2})
+}
+
+func TestSourceMapOutput(t *testing.T) {
+
+	cfg, out := testConfig()
+	maps := mapWriteFS{MapFS: fstest.MapFS{}}
+
+	cfg.SourceMaps = true
+	cfg.SourceMapOutput = maps
+
+	p := NewPipeline(cfg)
+
+	_, _, err := p.Build()
+	Equal(t, err, nil)
+
+	manifest, err := p.Manifest()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("js/app.js")
+	Equal(t, a.SourceMap, a.File+".map")
+	Equal(t, a.SourceMapURL, "")
+
+	b, err := fs.ReadFile(out, a.File)
+	Equal(t, err, nil)
+	Equal(t, strings.Contains(string(b), "sourceMappingURL"), false)
+
+	_, err = fs.ReadFile(out, a.SourceMap)
+	NotEqual(t, err, nil)
+
+	_, err = fs.ReadFile(maps, a.SourceMap)
+	Equal(t, err, nil)
+}
+
+func TestEncodeMappings(t *testing.T) {
+
+	buff := new(bytes.Buffer)
+
+	for _, v := range []int{0, 1, -1, 15, 16, 123, -123} {
+		writeVLQ(buff, v)
+		buff.WriteByte(' ')
+	}
+
+	Equal(t, buff.String(), "A C D e gB 2H 3H ")
+
+	mappings := encodeMappings([]mapping{
+		{line: 0, col: 0, source: 0, srcLine: 0, srcCol: 0},
+		{line: 0, col: 4, source: 1, srcLine: 2, srcCol: 1},
+		{line: 2, col: 1, source: 1, srcLine: 2, srcCol: 0},
+	})
+
+	Equal(t, mappings, "AAAA,ICEC;;CAAD")
+}
@@ -0,0 +1,134 @@
+package assets
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// htmlTag is the html tag referencing a css or js file.
+type htmlTag struct {
+	name    string
+	urlAttr string    // the attribute holding the URL of the file
+	attrs   []tagAttr // attributes preceding the URL
+	end     string    // the closing tag, if any
+}
+
+// tagAttr is an attribute of an htmlTag, a blank value rendering a boolean attribute.
+type tagAttr struct {
+	name  string
+	value string
+}
+
+var (
+	jsTag  = htmlTag{name: "script", urlAttr: "src", attrs: []tagAttr{{"type", "text/javascript"}}, end: "</script>"}
+	cssTag = htmlTag{name: "link", urlAttr: "href", attrs: []tagAttr{{"type", "text/css"}, {"rel", "stylesheet"}}}
+
+	attrNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.:-]*$`)
+)
+
+// parseTagAttrs parses the optional attribute arguments of the css_tag and js_tag funcs, either a
+// boolean attribute i.e. "defer" or "name=value" where the value may be quoted i.e. "media='print'".
+func parseTagAttrs(args []string) ([]tagAttr, error) {
+
+	attrs := make([]tagAttr, 0, len(args))
+
+	for _, arg := range args {
+
+		name, value, _ := strings.Cut(strings.TrimSpace(arg), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if !attrNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid tag attribute %q", arg)
+		}
+
+		switch {
+		case name == "src" || name == "href" || name == "integrity":
+			return nil, fmt.Errorf("invalid tag attribute %q, %s is set by the func", arg, name)
+		case strings.HasPrefix(name, "on"):
+			return nil, fmt.Errorf("invalid tag attribute %q, event handlers aren't allowed", arg)
+		}
+
+		value = strings.TrimSpace(value)
+
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		attrs = append(attrs, tagAttr{name: name, value: value})
+	}
+
+	return attrs, nil
+}
+
+// render returns the tag for the URL, the integrity and crossorigin attributes are rendered when integrity
+// isn't blank. attrs are added to the tag, replacing any attribute of the same name i.e. "type".
+func (t htmlTag) render(url string, integrity string, crossOrigin string, attrs []tagAttr) template.HTML {
+
+	all := append(append([]tagAttr(nil), t.attrs...), tagAttr{t.urlAttr, url})
+
+	if integrity != "" {
+		all = append(all, tagAttr{"integrity", integrity}, tagAttr{"crossorigin", crossOrigin})
+	}
+
+	var sb strings.Builder
+
+	t.writeStart(&sb, mergeAttrs(all, attrs))
+	sb.WriteString(t.end)
+
+	return template.HTML(sb.String())
+}
+
+// renderInline returns the tag containing content, which must not contain the closing tag,
+// with the attrs only.
+func (t htmlTag) renderInline(content string, attrs []tagAttr) template.HTML {
+
+	var sb strings.Builder
+
+	t.writeStart(&sb, attrs)
+	sb.WriteString(content + "</" + t.name + ">")
+
+	return template.HTML(sb.String())
+}
+
+// writeStart writes the start tag with attrs, the URL attribute is always rendered, even blank.
+func (t htmlTag) writeStart(sb *strings.Builder, attrs []tagAttr) {
+
+	sb.WriteString("<" + t.name)
+
+	for _, attr := range attrs {
+
+		sb.WriteString(" " + attr.name)
+
+		if attr.value != "" || attr.name == t.urlAttr {
+			sb.WriteString(`="` + template.HTMLEscapeString(attr.value) + `"`)
+		}
+	}
+
+	sb.WriteString(">")
+}
+
+// mergeAttrs adds attrs to all, replacing the attributes of the same name.
+func mergeAttrs(all []tagAttr, attrs []tagAttr) []tagAttr {
+
+outer:
+	for _, attr := range attrs {
+
+		for i := range all {
+			if all[i].name == attr.name {
+				all[i].value = attr.value
+				continue outer
+			}
+		}
+
+		all = append(all, attr)
+	}
+
+	return all
+}
+
+// commentSafeHTML makes name safe to write within an <!-- --> comment.
+func commentSafeHTML(name string) string {
+	return strings.Replace(template.HTMLEscapeString(name), "--", "-&#45;", -1)
+}
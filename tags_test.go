@@ -0,0 +1,70 @@
+package assets
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestTagAttributes(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	cfg.DevBundle = true
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	manifest, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	dev, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	cfg.Mode = Production
+
+	prod, err := NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	js := `{{ js_tag "js/app.js" "defer" "type=module" "nonce=\"a<b\"" }}`
+	css := `{{ css_tag "css/site.css" "media='print'" "data-x" }}`
+
+	Equal(t, renderTemplate(t, dev, js), `<script type="module" src="/static/js/app.js?bundle" defer nonce="a&lt;b"></script>`)
+	Equal(t, renderTemplate(t, prod, js), `<script type="module" src="`+manifest.URL("js/app.js")+`" defer nonce="a&lt;b"></script>`)
+
+	Equal(t, renderTemplate(t, dev, css), `<link type="text/css" rel="stylesheet" href="/static/css/site.css?bundle" media="print" data-x>`)
+	Equal(t, renderTemplate(t, prod, css), `<link type="text/css" rel="stylesheet" href="`+manifest.URL("css/site.css")+`" media="print" data-x>`)
+
+	// one tag per included file
+	cfg.Mode = Development
+	cfg.DevBundle = false
+
+	dev, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+	Equal(t, renderTemplate(t, dev, `{{ js_tag "js/app.js" "async" }}`), `<script type="text/javascript" src="/static/js/util.js" async></script>`+
+		`<script type="text/javascript" src="/static/js/lib.js" async></script><script type="text/javascript" src="/static/js/app.js" async></script>`)
+
+	// crossorigin replaces the Config's with SRI
+	cfg.Mode = Production
+	cfg.SRI = true
+
+	prod, err = NewPipeline(cfg).FuncMap()
+	Equal(t, err, nil)
+
+	a := manifest.Asset("js/app.js")
+	Equal(t, renderTemplate(t, prod, `{{ js_tag "js/app.js" "crossorigin=use-credentials" }}`),
+		`<script type="text/javascript" src="`+a.URL+`" integrity="`+a.Integrity+`" crossorigin="use-credentials"></script>`)
+
+	// test BAD input
+	for _, arg := range []string{"src=x.js", "HREF=x.css", "integrity=sha384-x", "onload=alert(1)", "a b", "=x", ""} {
+
+		tpl, err := template.New("test").Funcs(prod).Parse(`{{ js_tag "js/app.js" .}}`)
+		Equal(t, err, nil)
+
+		err = tpl.Execute(new(bytes.Buffer), arg)
+		NotEqual(t, err, nil)
+		MatchRegex(t, err.Error(), `invalid tag attribute`)
+	}
+}
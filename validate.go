@@ -0,0 +1,245 @@
+package assets
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// TemplateReport is the result of validating templates against a Manifest.
+type TemplateReport struct {
+	Missing      []TemplateRef // asset func calls of names missing from the manifest
+	Unreferenced []string      // manifest entries no template references, includes and css references excluded
+}
+
+// TemplateRef is a call of an asset func, i.e. css_tag or js_inline, with a constant name.
+type TemplateRef struct {
+	File string // the template file
+	Line int
+	Func string
+	Name string
+}
+
+// String returns the call i.e. `templates/index.html:3: js_tag "app.jss"`
+func (r TemplateRef) String() string {
+	return fmt.Sprintf("%s:%d: %s %q", r.File, r.Line, r.Func, r.Name)
+}
+
+// Err returns an error listing the missing names, nil if there are none.
+func (r *TemplateReport) Err() error {
+
+	if len(r.Missing) == 0 {
+		return nil
+	}
+
+	refs := make([]string, len(r.Missing))
+
+	for i, ref := range r.Missing {
+		refs[i] = ref.String()
+	}
+
+	return fmt.Errorf("missing from the manifest:\n%s", strings.Join(refs, "\n"))
+}
+
+// ValidateTemplates validates the templates of fsys matching the patterns against the manifest
+// created by Build, see the ValidateTemplates func; the func names are those of the Config.
+func (p *Pipeline) ValidateTemplates(fsys fs.FS, patterns ...string) (*TemplateReport, error) {
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	manifest, err := p.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := map[string]bool{}
+
+	for _, name := range funcNames {
+		funcs[p.funcName(name)] = true
+	}
+
+	return validateTemplates(fsys, patterns, manifest, funcs)
+}
+
+// ValidateTemplates parses the html/template files of fsys matching the patterns, as accepted by fs.Glob,
+// and reports the asset func calls, i.e. css_tag or asset_url, with a constant name missing from manifest,
+// as well as the manifest entries no template references. Calls with names that aren't constant are ignored.
+// The funcs are expected under their default names, see Pipeline.ValidateTemplates otherwise.
+func ValidateTemplates(fsys fs.FS, patterns []string, manifest *Manifest) (*TemplateReport, error) {
+
+	funcs := map[string]bool{}
+
+	for _, name := range funcNames {
+		funcs[name] = true
+	}
+
+	return validateTemplates(fsys, patterns, manifest, funcs)
+}
+
+// validateTemplates validates the templates against the manifest, funcs being the names of the asset funcs.
+func validateTemplates(fsys fs.FS, patterns []string, manifest *Manifest, funcs map[string]bool) (*TemplateReport, error) {
+
+	var files []string
+
+	for _, pattern := range patterns {
+
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern %q matches no files", pattern)
+		}
+
+		for _, match := range matches {
+			if !contains(files, match) {
+				files = append(files, match)
+			}
+		}
+	}
+
+	report := new(TemplateReport)
+	referenced := map[string]bool{}
+
+	for _, file := range files {
+
+		refs, err := templateRefs(fsys, file, funcs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range refs {
+
+			referenced[ref.Name] = true
+
+			if manifest.Asset(ref.Name) == nil {
+				report.Missing = append(report.Missing, ref)
+			}
+		}
+	}
+
+	// files bundled into, or referenced by, other assets aren't expected in templates
+	for _, a := range manifest.Assets {
+		for _, name := range append(append([]string(nil), a.Includes...), a.References...) {
+			referenced[name] = true
+		}
+	}
+
+	for _, name := range manifest.Names() {
+		if !referenced[name] {
+			report.Unreferenced = append(report.Unreferenced, name)
+		}
+	}
+
+	return report, nil
+}
+
+// templateRefs returns the calls of funcs with a constant name of the template file.
+func templateRefs(fsys fs.FS, file string, funcs map[string]bool) ([]TemplateRef, error) {
+
+	b, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the asset funcs are of interest, others aren't required to be defined
+	t := parse.New(file)
+	t.Mode = parse.SkipFuncCheck
+
+	trees := map[string]*parse.Tree{}
+
+	if _, err = t.Parse(string(b), "", "", trees); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(trees))
+
+	for name := range trees {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var refs []TemplateRef
+
+	add := func(fn string, arg parse.Node) {
+
+		if s, ok := arg.(*parse.StringNode); ok {
+			refs = append(refs, TemplateRef{File: file, Line: lineOf(b, int(s.Pos)), Func: fn, Name: s.Text})
+		}
+	}
+
+	var walk func(node parse.Node)
+
+	walk = func(node parse.Node) {
+
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, child := range n.Nodes {
+					walk(child)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+
+			for i, cmd := range n.Cmds {
+
+				if fn, ok := assetFunc(cmd.Args[0], funcs); ok {
+
+					if len(cmd.Args) > 1 {
+						add(fn, cmd.Args[1])
+					} else if i > 0 && len(n.Cmds[i-1].Args) == 1 {
+						// {{ "app.js" | js_tag }}
+						add(fn, n.Cmds[i-1].Args[0])
+					}
+				}
+
+				for _, arg := range cmd.Args {
+					walk(arg)
+				}
+			}
+		}
+	}
+
+	for _, name := range names {
+		walk(trees[name].Root)
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].Line < refs[j].Line })
+
+	return refs, nil
+}
+
+// assetFunc returns the name of the asset func, one of funcs, node calls.
+func assetFunc(node parse.Node, funcs map[string]bool) (string, bool) {
+
+	if id, ok := node.(*parse.IdentifierNode); ok && funcs[id.Ident] {
+		return id.Ident, true
+	}
+
+	return "", false
+}
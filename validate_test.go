@@ -0,0 +1,61 @@
+package assets
+
+import (
+	"testing"
+	"testing/fstest"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestValidateTemplates(t *testing.T) {
+
+	cfg, _ := testConfig()
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	templates := fstest.MapFS{
+		"templates/layout.html": {Data: []byte(`<html>
+<head>{{ css_tag "css/site.css" "media=screen" }}</head>
+<body>{{ template "content" . }}{{ js_tag "js/app.jss" }}</body>
+</html>`)},
+		"templates/index.html": {Data: []byte(`{{ define "content" }}
+{{ if .User }}<img src="{{ asset_url "images/avatar.png" }}">{{ else }}{{ "js/app.js" | js_tag }}{{ end }}
+{{ range .Items }}{{ custom_func . }}{{ js_tag . }}{{ end }}
+{{ end }}`)},
+		"templates/other.tmpl": {Data: []byte(`{{ with (asset_url "images/logo.png") }}{{ . }}{{ end }}`)},
+	}
+
+	report, err := NewPipeline(cfg).ValidateTemplates(templates, "templates/*.html", "templates/*.tmpl")
+	Equal(t, err, nil)
+	Equal(t, len(report.Missing), 2)
+	Equal(t, report.Missing[0].String(), `templates/index.html:2: asset_url "images/avatar.png"`)
+	Equal(t, report.Missing[1], TemplateRef{File: "templates/layout.html", Line: 3, Func: "js_tag", Name: "js/app.jss"})
+	Equal(t, report.Unreferenced, []string(nil))
+	Equal(t, report.Err().Error(), "missing from the manifest:\n"+
+		`templates/index.html:2: asset_url "images/avatar.png"`+"\n"+
+		`templates/layout.html:3: js_tag "js/app.jss"`)
+
+	report, err = NewPipeline(cfg).ValidateTemplates(templates, "templates/layout.html")
+	Equal(t, err, nil)
+	Equal(t, len(report.Missing), 1)
+	Equal(t, report.Unreferenced, []string{"images/logo.png", "js/app.js"})
+
+	templates["templates/layout.html"] = &fstest.MapFile{Data: []byte(`{{ css_tag "css/site.css" }}{{ js_tag "js/app.js" }}{{ asset_url "images/logo.png" }}`)}
+
+	report, err = NewPipeline(cfg).ValidateTemplates(templates, "templates/layout.html")
+	Equal(t, err, nil)
+	Equal(t, len(report.Missing), 0)
+	Equal(t, report.Err(), nil)
+
+	// test BAD input
+	_, err = NewPipeline(cfg).ValidateTemplates(templates, "views/*.html")
+	NotEqual(t, err, nil)
+	Equal(t, err.Error(), `pattern "views/*.html" matches no files`)
+
+	templates["templates/bad.html"] = &fstest.MapFile{Data: []byte(`{{ if }}`)}
+
+	_, err = NewPipeline(cfg).ValidateTemplates(templates, "templates/bad.html")
+	NotEqual(t, err, nil)
+	MatchRegex(t, err.Error(), `^template: templates/bad\.html:1: missing value for if$`)
+}
@@ -0,0 +1,260 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-playground/bundler"
+)
+
+// Rebuild is the result of a single rebuild performed by Watch.
+type Rebuild struct {
+	Changed   []string                 // changed, added and removed files within the InputDir
+	Processed []*bundler.ProcessedFile // files rebuilt because they, or one of their includes, changed
+	Duration  time.Duration
+	Err       error
+}
+
+// Update rebuilds only what is affected by the changed, added or removed files, names within
+// the InputDir, and rewrites the manifest and build cache; files including a changed file are
+// rebuilt too. The output of Build is ignored when written within the InputDir.
+// Build must have been run beforehand. It returns the rebuilt files.
+func (p *Pipeline) Update(changed []string) ([]*bundler.ProcessedFile, error) {
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	initMinifier()
+
+	out, ok := p.out.(WriteFS)
+	if !ok {
+		return nil, errors.New("output filesystem is not writable")
+	}
+
+	manifest, err := p.loadManifest(out)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := p.newUpdateCacheState(out)
+	isChanged := make(map[string]bool, len(changed))
+	rebuild := map[string]bool{}
+	isOutput := p.outputFilter()
+
+	for _, name := range changed {
+
+		// written within the InputDir the output of previous builds isn't a source file
+		if isOutput(name) {
+			continue
+		}
+
+		isChanged[name] = true
+		rebuild[name] = true
+	}
+
+	for name, a := range manifest.Assets {
+		for _, include := range append(a.Includes, a.References...) {
+			if isChanged[include] {
+				rebuild[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(rebuild))
+
+	for name := range rebuild {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var processed []*bundler.ProcessedFile
+
+	for _, name := range names {
+
+		old := manifest.Assets[name]
+
+		if _, err = fs.Stat(p.src, name); errors.Is(err, fs.ErrNotExist) {
+
+			if !p.isProcessed(name) {
+				out.Remove(path.Join(p.prefix, name))
+			}
+
+			if old != nil {
+				removeAsset(out, old)
+				delete(manifest.Assets, name)
+			}
+
+			delete(cache.next.Entries, name)
+
+			continue
+		}
+
+		var a *Asset
+
+		if p.isProcessed(name) {
+			a, err = p.bundleFile(out, name, path.Ext(name), cache)
+		} else {
+			a, err = p.copyFile(out, name, cache)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if old != nil && old.File != a.File {
+			removeAsset(out, old)
+		}
+
+		manifest.Assets[name] = a
+		processed = append(processed, &bundler.ProcessedFile{OriginalFilename: path.Join(p.prefix, name), NewFilename: a.File})
+	}
+
+	assets := make([]*Asset, 0, len(manifest.Assets))
+
+	for _, name := range manifest.Names() {
+		assets = append(assets, manifest.Assets[name])
+	}
+
+	if err = checkCollisions(assets); err != nil {
+		return nil, err
+	}
+
+	if err = p.writeManifest(out, assets); err != nil {
+		return nil, err
+	}
+
+	out.Remove(path.Join(p.prefix, legacyManifestFile))
+
+	if err = p.writeCache(out, cache); err != nil {
+		return nil, err
+	}
+
+	return processed, nil
+}
+
+// removeAsset removes the hashed file of a, all of it's variants and it's source map.
+func removeAsset(out WriteFS, a *Asset) {
+
+	for _, file := range a.files() {
+		out.Remove(file)
+	}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot returns the modification time and size of every file within the InputDir.
+func (p *Pipeline) snapshot() (map[string]fileState, error) {
+
+	files := map[string]fileState{}
+
+	err := p.walkFiles(".", func(name string, fi fs.FileInfo) error {
+		files[name] = fileState{modTime: fi.ModTime(), size: fi.Size()}
+		return nil
+	})
+
+	return files, err
+}
+
+// Watch polls the InputDir every interval, by comparing modification times and sizes so that
+// it works on every platform and filesystem, and calls Update with the changed files until
+// ctx is done. fn is called with the result of every rebuild, failed rebuilds don't stop Watch.
+// Build should be run before Watch.
+func (p *Pipeline) Watch(ctx context.Context, interval time.Duration, fn func(*Rebuild)) error {
+
+	prev, err := p.snapshot()
+	if err != nil {
+		return err
+	}
+
+	// files of failed rebuilds are retried along with the next change
+	var failed []string
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := p.snapshot()
+		if err != nil {
+			fn(&Rebuild{Err: err})
+			continue
+		}
+
+		changed := changedFiles(prev, current)
+		prev = current
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		start := time.Now()
+		r := &Rebuild{Changed: changed}
+		update := mergeNames(changed, failed)
+
+		r.Processed, r.Err = p.Update(update)
+		r.Duration = time.Since(start)
+
+		failed = nil
+
+		if r.Err != nil {
+			failed = update
+		}
+
+		fn(r)
+	}
+}
+
+// changedFiles returns the sorted names of the files that were changed, added or removed.
+func changedFiles(prev map[string]fileState, current map[string]fileState) []string {
+
+	var changed []string
+
+	for name, state := range current {
+		if old, ok := prev[name]; !ok || !old.modTime.Equal(state.modTime) || old.size != state.size {
+			changed = append(changed, name)
+		}
+	}
+
+	for name := range prev {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// mergeNames returns the sorted, unique, names of a and b.
+func mergeNames(a []string, b []string) []string {
+
+	names := make([]string, 0, len(a)+len(b))
+	seen := make(map[string]bool, len(a)+len(b))
+
+	for _, list := range [][]string{a, b} {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
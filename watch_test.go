@@ -0,0 +1,265 @@
+package assets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestUpdate(t *testing.T) {
+
+	in := fstest.MapFS{
+		"static/js/app.js":   {Data: []byte("//include(js/lib.js)\nvar app = 1;\n")},
+		"static/js/lib.js":   {Data: []byte("var lib = 1;\n")},
+		"static/js/other.js": {Data: []byte("var other = 1;\n")},
+		"static/logo.png":    {Data: []byte("PNG")},
+	}
+	var written, removed []string
+
+	out := recordingWriteFS{mapWriteFS: mapWriteFS{MapFS: fstest.MapFS{}}, written: &written, removed: &removed}
+
+	cfg := Config{
+		InputDir:      "static",
+		Input:         in,
+		Output:        out,
+		RelativeToDir: true,
+		Extensions:    map[string]struct{}{".js": {}},
+	}
+
+	_, _, err := NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	before, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+
+	// changing an include rebuilds every file including it
+	in["static/js/lib.js"] = &fstest.MapFile{Data: []byte("var lib = 2;\n")}
+	in["static/logo.png"] = &fstest.MapFile{Data: []byte("PNG2")}
+
+	processed, err := NewPipeline(cfg).Update([]string{"js/lib.js", "logo.png"})
+	Equal(t, err, nil)
+	Equal(t, len(processed), 3)
+	Equal(t, processed[0].OriginalFilename, "static/js/app.js")
+	Equal(t, processed[1].OriginalFilename, "static/js/lib.js")
+	Equal(t, processed[2].OriginalFilename, "static/logo.png")
+	Equal(t, string(out.MapFS["static/logo.png"].Data), "PNG2")
+
+	after, err := NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	NotEqual(t, after.Asset("js/app.js").File, before.Asset("js/app.js").File)
+	Equal(t, after.Asset("js/other.js"), before.Asset("js/other.js"))
+
+	_, ok := out.MapFS[before.Asset("js/app.js").File]
+	Equal(t, ok, false)
+
+	// added and removed files
+	delete(in, "static/js/other.js")
+	delete(in, "static/logo.png")
+	in["static/js/new.js"] = &fstest.MapFile{Data: []byte("var n = 1;\n")}
+
+	processed, err = NewPipeline(cfg).Update([]string{"js/new.js", "js/other.js", "logo.png"})
+	Equal(t, err, nil)
+	Equal(t, len(processed), 1)
+	Equal(t, processed[0].OriginalFilename, "static/js/new.js")
+
+	after, err = NewPipeline(cfg).Manifest()
+	Equal(t, err, nil)
+	Equal(t, after.Names(), []string{"js/app.js", "js/lib.js", "js/new.js"})
+
+	_, ok = out.MapFS[before.Asset("js/other.js").File]
+	Equal(t, ok, false)
+
+	_, ok = out.MapFS["static/logo.png"]
+	Equal(t, ok, false)
+
+	// the build cache is updated, the next Build reuses the output
+	cache, err := NewPipeline(cfg).readCache(out)
+	Equal(t, err, nil)
+	Equal(t, len(cache.Entries), 3)
+	Equal(t, cache.Entries["js/app.js"].Asset.File, after.Asset("js/app.js").File)
+
+	written = nil
+
+	_, _, err = NewPipeline(cfg).Build()
+	Equal(t, err, nil)
+
+	sort.Strings(written)
+	Equal(t, written, []string{"static/assets-cache.json", "static/manifest.json"})
+
+	// test BAD input
+	in["static/js/lib.js"] = &fstest.MapFile{Data: []byte("//include(js/missing.js)")}
+
+	_, err = NewPipeline(cfg).Update([]string{"js/lib.js"})
+	NotEqual(t, err, nil)
+
+	cfg.Output = mapWriteFS{MapFS: fstest.MapFS{}}
+
+	_, err = NewPipeline(cfg).Update([]string{"js/lib.js"})
+	NotEqual(t, err, nil)
+}
+
+func TestWatch(t *testing.T) {
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "static")
+
+	err := os.MkdirAll(input, 0777)
+	Equal(t, err, nil)
+
+	err = os.WriteFile(filepath.Join(input, "app.js"), []byte("var app = 1;"), 0644)
+	Equal(t, err, nil)
+
+	p := NewPipeline(Config{
+		InputDir:   input,
+		OutputDir:  filepath.Join(dir, "public"),
+		Extensions: map[string]struct{}{".js": {}},
+	})
+
+	_, _, err = p.Build()
+	Equal(t, err, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rebuilds := make(chan *Rebuild, 10)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- p.Watch(ctx, 10*time.Millisecond, func(r *Rebuild) { rebuilds <- r })
+	}()
+
+	// let the initial snapshot be taken
+	time.Sleep(200 * time.Millisecond)
+
+	writeFileAtomic(t, filepath.Join(input, "app.js"), []byte("var app = 22;"))
+
+	r := nextRebuild(t, rebuilds)
+	Equal(t, r.Err, nil)
+	Equal(t, r.Changed, []string{"app.js"})
+	Equal(t, len(r.Processed), 1)
+
+	// failures are reported and watching continues
+	writeFileAtomic(t, filepath.Join(input, "broken.js"), []byte("//include(missing.js)"))
+
+	r = nextRebuild(t, rebuilds)
+	NotEqual(t, r.Err, nil)
+	Equal(t, r.Changed, []string{"broken.js"})
+
+	writeFileAtomic(t, filepath.Join(input, "missing.js"), []byte("var m = 1;"))
+
+	r = nextRebuild(t, rebuilds)
+	Equal(t, r.Err, nil)
+	Equal(t, r.Changed, []string{"missing.js"})
+	Equal(t, len(r.Processed), 2)
+
+	cancel()
+	Equal(t, watchDone(t, done), context.Canceled)
+}
+
+func TestWatchOverlappingOutput(t *testing.T) {
+
+	// the CLI default, the output is written next to the source files
+	t.Chdir(t.TempDir())
+
+	err := os.MkdirAll("static", 0777)
+	Equal(t, err, nil)
+
+	err = os.WriteFile(filepath.Join("static", "app.js"), []byte("var app = 1;"), 0644)
+	Equal(t, err, nil)
+
+	err = os.WriteFile(filepath.Join("static", "logo.png"), []byte("PNG"), 0644)
+	Equal(t, err, nil)
+
+	p := NewPipeline(Config{
+		InputDir:   "static",
+		Extensions: map[string]struct{}{".js": {}},
+	})
+
+	_, _, err = p.Build()
+	Equal(t, err, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rebuilds := make(chan *Rebuild, 10)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- p.Watch(ctx, 10*time.Millisecond, func(r *Rebuild) { rebuilds <- r })
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	writeFileAtomic(t, filepath.Join("static", "app.js"), []byte("var app = 22;"))
+
+	r := nextRebuild(t, rebuilds)
+	Equal(t, r.Err, nil)
+	Equal(t, r.Changed, []string{"app.js"})
+	Equal(t, len(r.Processed), 1)
+
+	// the files written by the rebuild don't trigger another one
+	select {
+	case r = <-rebuilds:
+		t.Fatalf("unexpected rebuild of %v", r.Changed)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	Equal(t, watchDone(t, done), context.Canceled)
+
+	manifest, err := p.Manifest()
+	Equal(t, err, nil)
+	Equal(t, manifest.Names(), []string{"app.js", "logo.png"})
+
+	// the output passed to Update is ignored
+	processed, err := p.Update([]string{manifest.Asset("app.js").File[len("static/"):], "manifest.json"})
+	Equal(t, err, nil)
+	Equal(t, len(processed), 0)
+}
+
+// writeFileAtomic writes the file by renaming a temporary one, so that a poll never sees it partially written.
+func writeFileAtomic(t *testing.T, name string, data []byte) {
+
+	tmp := filepath.Join(t.TempDir(), filepath.Base(name))
+
+	err := os.WriteFile(tmp, data, 0644)
+	Equal(t, err, nil)
+
+	err = os.Rename(tmp, name)
+	Equal(t, err, nil)
+}
+
+// nextRebuild returns the next rebuild reported by Watch, failing the test if none is within a few seconds.
+func nextRebuild(t *testing.T, rebuilds <-chan *Rebuild) *Rebuild {
+
+	select {
+	case r := <-rebuilds:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a rebuild")
+		return nil
+	}
+}
+
+// watchDone returns the error Watch returned, failing the test if it didn't return within a few seconds.
+func watchDone(t *testing.T, done <-chan error) error {
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Watch to return")
+		return nil
+	}
+}
+
+func TestMergeNames(t *testing.T) {
+	Equal(t, mergeNames([]string{"c", "a"}, []string{"b", "a"}), []string{"a", "b", "c"})
+	Equal(t, mergeNames(nil, nil), []string{})
+}